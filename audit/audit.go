@@ -0,0 +1,174 @@
+package audit
+
+import (
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/casimir/compulsive"
+	"github.com/casimir/compulsive/index"
+)
+
+type (
+	// Advisory is a vulnerability affecting an installed package.
+	Advisory struct {
+		ID      string
+		Aliases []string
+		Summary string
+		Score   float64
+		Fixed   string
+	}
+
+	// Finding gathers the advisories affecting a package.
+	Finding struct {
+		Package    compulsive.Package
+		Ecosystem  string
+		Advisories []Advisory
+	}
+)
+
+// Score gives the highest score of the advisories of the finding.
+func (f Finding) Score() float64 {
+	var score float64
+	for _, it := range f.Advisories {
+		if it.Score > score {
+			score = it.Score
+		}
+	}
+	return score
+}
+
+// isVersion tells whether a package version is an actual version rather
+// than a requirement like ">=1.2".
+func isVersion(version string) bool {
+	version = strings.TrimPrefix(version, "v")
+	return version != "" && version[0] >= '0' && version[0] <= '9'
+}
+
+func toAdvisories(vulns []Vulnerability, ecosystem, name, version, prefix string) []Advisory {
+	var advisories []Advisory
+	for _, it := range vulns {
+		_, fixed := it.Matches(ecosystem, name, version)
+		summary := it.Summary
+		if summary == "" {
+			summary = strings.SplitN(strings.TrimSpace(it.Details), "\n", 2)[0]
+		}
+		advisories = append(advisories, Advisory{
+			ID:      it.ID,
+			Aliases: it.Aliases,
+			Summary: prefix + summary,
+			Score:   it.Score(),
+			Fixed:   fixed,
+		})
+	}
+	return advisories
+}
+
+// Query is a lookup of the advisories affecting a package version.
+type Query struct {
+	Ecosystem string
+	Name      string
+	Version   string
+	// prefix is prepended to the summary of the advisories found.
+	prefix string
+}
+
+// packageQueries gives the ecosystem of a package and the lookups auditing
+// it. Go binaries are also checked against the advisories of the standard
+// library they embed, and by the module they were built from rather than
+// their package path, as crates installed in several roots are by their
+// crate name. Packages with a Source attribute come from outside the public
+// registry of the ecosystem and are not looked up.
+func packageQueries(pkg compulsive.Package) (string, []Query) {
+	var queries []Query
+	if toolchain := pkg.Attributes["Toolchain"]; strings.HasPrefix(toolchain, "go") {
+		version := strings.TrimPrefix(toolchain, "go")
+		queries = append(queries, Query{Ecosystem: "Go", Name: "stdlib", Version: version, prefix: "stdlib " + version + ": "})
+	}
+	pvd, ok := pkg.Provider.(compulsive.EcosystemProvider)
+	if !ok || !isVersion(pkg.Version) || pkg.Attributes["Source"] != "" {
+		return "", queries
+	}
+	name := pkg.Name
+	if module := pkg.Attributes["Module"]; module != "" {
+		name = module
+	} else if crate := pkg.Attributes["Crate"]; crate != "" {
+		name = crate
+	}
+	return pvd.Ecosystem(), append(queries, Query{Ecosystem: pvd.Ecosystem(), Name: name, Version: pkg.Version})
+}
+
+// CheckPackage gives the advisories affecting a package, an empty finding
+// being returned for providers without OSV ecosystem.
+func CheckPackage(src Source, pkg compulsive.Package) (Finding, error) {
+	finding := Finding{Package: pkg}
+	var queries []Query
+	finding.Ecosystem, queries = packageQueries(pkg)
+	for _, it := range queries {
+		vulns, err := src.Query(it.Ecosystem, it.Name, it.Version)
+		if err != nil {
+			return finding, err
+		}
+		finding.Advisories = append(finding.Advisories, toAdvisories(vulns, it.Ecosystem, it.Name, it.Version, it.prefix)...)
+	}
+	sort.Slice(finding.Advisories, func(i, j int) bool {
+		return finding.Advisories[i].Score > finding.Advisories[j].Score
+	})
+	return finding, nil
+}
+
+// Prefetch looks up at once the advisories of the packages about to be
+// checked, for sources supporting it.
+func Prefetch(src Source, pkgs []compulsive.Package) {
+	batch, ok := src.(BatchSource)
+	if !ok {
+		return
+	}
+	var queries []Query
+	for _, it := range pkgs {
+		_, pkgQueries := packageQueries(it)
+		queries = append(queries, pkgQueries...)
+	}
+	if err := batch.Prefetch(queries); err != nil {
+		log.Printf("could not prefetch advisories: %s", err)
+	}
+}
+
+// Audit checks every package of the index, giving the affected ones, most
+// severe first.
+func Audit(src Source, idx index.Index) []Finding {
+	var pkgs []compulsive.Package
+	for _, it := range idx {
+		for _, pkg := range it {
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	Prefetch(src, pkgs)
+	var findings []Finding
+	for _, pkg := range pkgs {
+		finding, err := CheckPackage(src, pkg)
+		if err != nil {
+			log.Printf("failed to audit package %q: %s", pkg.Name, err)
+			continue
+		}
+		if len(finding.Advisories) > 0 {
+			findings = append(findings, finding)
+		}
+	}
+	SortFindings(findings)
+	return findings
+}
+
+// SortFindings orders findings by decreasing severity then by name.
+func SortFindings(findings []Finding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		if si, sj := findings[i].Score(), findings[j].Score(); si != sj {
+			return si > sj
+		}
+		return pkgID(findings[i].Package) < pkgID(findings[j].Package)
+	})
+}
+
+func pkgID(pkg compulsive.Package) string {
+	return pkg.Provider.Name() + "/" + pkg.Name
+}
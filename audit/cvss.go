@@ -0,0 +1,78 @@
+package audit
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+var cvss3Weights = map[string]map[string]float64{
+	"AV": {"N": 0.85, "A": 0.62, "L": 0.55, "P": 0.2},
+	"AC": {"L": 0.77, "H": 0.44},
+	"UI": {"N": 0.85, "R": 0.62},
+	"C":  {"H": 0.56, "L": 0.22, "N": 0},
+	"I":  {"H": 0.56, "L": 0.22, "N": 0},
+	"A":  {"H": 0.56, "L": 0.22, "N": 0},
+}
+
+// roundUp rounds to one decimal as defined by the CVSS v3.1 specification.
+func roundUp(value float64) float64 {
+	n := int(math.Round(value * 100000))
+	if n%10000 == 0 {
+		return float64(n) / 100000
+	}
+	return float64(n/10000+1) / 10
+}
+
+// CVSS3BaseScore computes the base score of a CVSS v3 vector.
+func CVSS3BaseScore(vector string) (float64, error) {
+	metrics := make(map[string]string)
+	for _, it := range strings.Split(vector, "/") {
+		parts := strings.SplitN(it, ":", 2)
+		if len(parts) == 2 {
+			metrics[parts[0]] = parts[1]
+		}
+	}
+	if !strings.HasPrefix(metrics["CVSS"], "3") {
+		return 0, fmt.Errorf("not a CVSS v3 vector: %s", vector)
+	}
+	values := make(map[string]float64)
+	for metric, weights := range cvss3Weights {
+		value, ok := weights[metrics[metric]]
+		if !ok {
+			return 0, fmt.Errorf("invalid %s metric in %s", metric, vector)
+		}
+		values[metric] = value
+	}
+	changed := metrics["S"] == "C"
+	var pr float64
+	switch metrics["PR"] {
+	case "N":
+		pr = 0.85
+	case "L":
+		pr = 0.62
+		if changed {
+			pr = 0.68
+		}
+	case "H":
+		pr = 0.27
+		if changed {
+			pr = 0.5
+		}
+	default:
+		return 0, fmt.Errorf("invalid PR metric in %s", vector)
+	}
+	iss := 1 - (1-values["C"])*(1-values["I"])*(1-values["A"])
+	impact := 6.42 * iss
+	if changed {
+		impact = 7.52*(iss-0.029) - 3.25*math.Pow(iss-0.02, 15)
+	}
+	if impact <= 0 {
+		return 0, nil
+	}
+	exploitability := 8.22 * values["AV"] * values["AC"] * pr * values["UI"]
+	if changed {
+		return roundUp(math.Min(1.08*(impact+exploitability), 10)), nil
+	}
+	return roundUp(math.Min(impact+exploitability, 10)), nil
+}
@@ -0,0 +1,157 @@
+package audit
+
+import (
+	"strings"
+
+	"github.com/casimir/compulsive"
+)
+
+type (
+	osvEvent struct {
+		Introduced   string `json:"introduced,omitempty"`
+		Fixed        string `json:"fixed,omitempty"`
+		LastAffected string `json:"last_affected,omitempty"`
+	}
+
+	osvRange struct {
+		Type   string     `json:"type"`
+		Events []osvEvent `json:"events"`
+	}
+
+	osvAffected struct {
+		Package struct {
+			Ecosystem string `json:"ecosystem"`
+			Name      string `json:"name"`
+		} `json:"package"`
+		Ranges           []osvRange `json:"ranges"`
+		Versions         []string   `json:"versions"`
+		DatabaseSpecific struct {
+			Severity string `json:"severity"`
+		} `json:"database_specific"`
+	}
+
+	// Vulnerability is an advisory in the OSV format.
+	Vulnerability struct {
+		ID       string   `json:"id"`
+		Summary  string   `json:"summary"`
+		Details  string   `json:"details"`
+		Aliases  []string `json:"aliases"`
+		Severity []struct {
+			Type  string `json:"type"`
+			Score string `json:"score"`
+		} `json:"severity"`
+		Affected         []osvAffected `json:"affected"`
+		DatabaseSpecific struct {
+			Severity string `json:"severity"`
+		} `json:"database_specific"`
+	}
+)
+
+// normalizeName makes package names comparable, PyPI names being case and
+// separator insensitive.
+func normalizeName(ecosystem, name string) string {
+	if ecosystem == "PyPI" {
+		return strings.ToLower(strings.NewReplacer("_", "-", ".", "-").Replace(name))
+	}
+	return name
+}
+
+// affects tells whether a version falls in a range, giving the version
+// fixing it if any.
+func (r osvRange) affects(version string) (bool, string) {
+	if r.Type == "GIT" {
+		return false, ""
+	}
+	affected, fixed := false, ""
+	for _, it := range r.Events {
+		switch {
+		case it.Introduced != "":
+			if it.Introduced == "0" || compulsive.CompareVersions(version, it.Introduced) >= 0 {
+				affected, fixed = true, ""
+			}
+		case it.Fixed != "":
+			if compulsive.CompareVersions(version, it.Fixed) >= 0 {
+				affected = false
+			} else if affected && fixed == "" {
+				fixed = it.Fixed
+			}
+		case it.LastAffected != "":
+			if compulsive.CompareVersions(version, it.LastAffected) > 0 {
+				affected = false
+			}
+		}
+	}
+	return affected, fixed
+}
+
+// Matches tells whether the advisory affects a package version, giving the
+// version fixing it if known.
+func (v Vulnerability) Matches(ecosystem, name, version string) (bool, string) {
+	name = normalizeName(ecosystem, name)
+	for _, affected := range v.Affected {
+		if affected.Package.Ecosystem != ecosystem || normalizeName(ecosystem, affected.Package.Name) != name {
+			continue
+		}
+		for _, it := range affected.Versions {
+			if compulsive.CompareVersions(it, version) == 0 {
+				fixed := ""
+				for _, r := range affected.Ranges {
+					if ok, f := r.affects(version); ok {
+						fixed = f
+					}
+				}
+				return true, fixed
+			}
+		}
+		for _, r := range affected.Ranges {
+			if ok, fixed := r.affects(version); ok {
+				return true, fixed
+			}
+		}
+	}
+	return false, ""
+}
+
+// Score gives the CVSS base score of the advisory, computed from its CVSS
+// v3 vector or estimated from its severity rating.
+func (v Vulnerability) Score() float64 {
+	for _, it := range v.Severity {
+		if it.Type == "CVSS_V3" {
+			if score, err := CVSS3BaseScore(it.Score); err == nil {
+				return score
+			}
+		}
+	}
+	rating := v.DatabaseSpecific.Severity
+	for _, it := range v.Affected {
+		if rating == "" {
+			rating = it.DatabaseSpecific.Severity
+		}
+	}
+	switch strings.ToUpper(rating) {
+	case "CRITICAL":
+		return 9.0
+	case "HIGH":
+		return 7.0
+	case "MODERATE", "MEDIUM":
+		return 4.0
+	case "LOW":
+		return 0.1
+	}
+	return 0
+}
+
+// SeverityRating gives the qualitative rating of a CVSS score.
+func SeverityRating(score float64) string {
+	switch {
+	case score >= 9.0:
+		return "CRITICAL"
+	case score >= 7.0:
+		return "HIGH"
+	case score >= 4.0:
+		return "MEDIUM"
+	case score > 0:
+		return "LOW"
+	}
+	return "UNKNOWN"
+}
@@ -0,0 +1,55 @@
+package audit
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMatches(t *testing.T) {
+	raw := []byte(`{
+  "id": "RUSTSEC-2021-0001",
+  "affected": [{
+    "package": {"ecosystem": "crates.io", "name": "demo"},
+    "ranges": [{
+      "type": "SEMVER",
+      "events": [
+        {"introduced": "0"}, {"fixed": "1.2.0"},
+        {"introduced": "2.0.0"}, {"fixed": "2.0.3"}
+      ]
+    }]
+  }]
+}`)
+	var vuln Vulnerability
+	if err := json.Unmarshal(raw, &vuln); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		version  string
+		affected bool
+		fixed    string
+	}{
+		{"1.1.9", true, "1.2.0"},
+		{"1.2.0", false, ""},
+		{"2.0.1", true, "2.0.3"},
+		{"2.1.0", false, ""},
+	}
+	for _, it := range cases {
+		affected, fixed := vuln.Matches("crates.io", "demo", it.version)
+		if affected != it.affected || fixed != it.fixed {
+			t.Errorf("%s: got (%v, %q), expected (%v, %q)", it.version, affected, fixed, it.affected, it.fixed)
+		}
+	}
+}
+
+func TestCVSS3BaseScore(t *testing.T) {
+	cases := map[string]float64{
+		"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H": 9.8,
+		"CVSS:3.1/AV:N/AC:L/PR:N/UI:R/S:C/C:L/I:L/A:N": 6.1,
+		"CVSS:3.0/AV:L/AC:L/PR:L/UI:N/S:U/C:N/I:N/A:H": 5.5,
+	}
+	for vector, expected := range cases {
+		if got, err := CVSS3BaseScore(vector); err != nil || got != expected {
+			t.Errorf("%s: got %.1f (%v), expected %.1f", vector, got, err, expected)
+		}
+	}
+}
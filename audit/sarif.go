@@ -0,0 +1,145 @@
+package audit
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/casimir/compulsive"
+	"github.com/casimir/compulsive/index"
+)
+
+type (
+	sarifText struct {
+		Text string `json:"text"`
+	}
+
+	sarifRule struct {
+		ID               string            `json:"id"`
+		ShortDescription sarifText         `json:"shortDescription"`
+		HelpURI          string            `json:"helpUri"`
+		Properties       map[string]string `json:"properties"`
+	}
+
+	sarifPhysicalLocation struct {
+		ArtifactLocation struct {
+			URI string `json:"uri"`
+		} `json:"artifactLocation"`
+	}
+
+	sarifLogicalLocation struct {
+		FullyQualifiedName string `json:"fullyQualifiedName"`
+		Kind               string `json:"kind"`
+	}
+
+	sarifLocation struct {
+		PhysicalLocation *sarifPhysicalLocation `json:"physicalLocation,omitempty"`
+		LogicalLocations []sarifLogicalLocation `json:"logicalLocations"`
+	}
+
+	sarifResult struct {
+		RuleID    string          `json:"ruleId"`
+		Level     string          `json:"level"`
+		Message   sarifText       `json:"message"`
+		Locations []sarifLocation `json:"locations"`
+	}
+
+	sarifRun struct {
+		Tool struct {
+			Driver struct {
+				Name           string      `json:"name"`
+				InformationURI string      `json:"informationUri"`
+				Rules          []sarifRule `json:"rules"`
+			} `json:"driver"`
+		} `json:"tool"`
+		Results []sarifResult `json:"results"`
+	}
+
+	// SARIF is a SARIF 2.1.0 log.
+	SARIF struct {
+		Schema  string     `json:"$schema"`
+		Version string     `json:"version"`
+		Runs    []sarifRun `json:"runs"`
+	}
+)
+
+func sarifLevel(score float64) string {
+	switch {
+	case score >= 7.0:
+		return "error"
+	case score >= 4.0:
+		return "warning"
+	}
+	return "note"
+}
+
+// binaryLocation points to the first binary of a package found on disk, as
+// a file URI. Packages without binaries only have a logical location.
+func binaryLocation(pkg compulsive.Package) *sarifPhysicalLocation {
+	for _, it := range pkg.Binaries {
+		path := index.LocateBinary(pkg, it)
+		if path == "" {
+			continue
+		}
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+		path = filepath.ToSlash(path)
+		if !strings.HasPrefix(path, "/") {
+			// drive letter paths, e.g. file:///C:/bin/tool.exe
+			path = "/" + path
+		}
+		var loc sarifPhysicalLocation
+		loc.ArtifactLocation.URI = (&url.URL{Scheme: "file", Path: path}).String()
+		return &loc
+	}
+	return nil
+}
+
+// ToSARIF converts findings to a SARIF log, each advisory being a rule and
+// each affected package a result.
+func ToSARIF(findings []Finding) SARIF {
+	var run sarifRun
+	run.Tool.Driver.Name = "compulsive"
+	run.Tool.Driver.InformationURI = "https://github.com/casimir/compulsive"
+	run.Tool.Driver.Rules = []sarifRule{}
+	run.Results = []sarifResult{}
+	rules := make(map[string]bool)
+	for _, finding := range findings {
+		id := pkgID(finding.Package)
+		for _, it := range finding.Advisories {
+			if !rules[it.ID] {
+				rules[it.ID] = true
+				run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+					ID:               it.ID,
+					ShortDescription: sarifText{Text: it.Summary},
+					HelpURI:          "https://osv.dev/vulnerability/" + it.ID,
+					Properties:       map[string]string{"security-severity": fmt.Sprintf("%.1f", it.Score)},
+				})
+			}
+			message := fmt.Sprintf("%s %s is affected by %s", id, finding.Package.Version, it.ID)
+			if len(it.Aliases) > 0 {
+				message += " (" + strings.Join(it.Aliases, ", ") + ")"
+			}
+			if it.Fixed != "" {
+				message += ", fixed in " + it.Fixed
+			}
+			loc := sarifLocation{
+				PhysicalLocation: binaryLocation(finding.Package),
+				LogicalLocations: []sarifLogicalLocation{{FullyQualifiedName: id, Kind: "module"}},
+			}
+			run.Results = append(run.Results, sarifResult{
+				RuleID:    it.ID,
+				Level:     sarifLevel(it.Score),
+				Message:   sarifText{Text: message},
+				Locations: []sarifLocation{loc},
+			})
+		}
+	}
+	return SARIF{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	}
+}
@@ -0,0 +1,53 @@
+package audit
+
+import (
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"github.com/casimir/compulsive"
+)
+
+type fakeProvider struct {
+	name string
+	dirs []string
+}
+
+func (p fakeProvider) Name() string                                    { return p.name }
+func (p fakeProvider) IsAvailable() bool                               { return true }
+func (p fakeProvider) Sync() error                                     { return nil }
+func (p fakeProvider) List() ([]compulsive.Package, error)             { return nil, nil }
+func (p fakeProvider) UpdateCommand(pkgs ...compulsive.Package) string { return "" }
+func (p fakeProvider) BinaryDirs() []string                            { return p.dirs }
+
+func TestToSARIFLocations(t *testing.T) {
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "rg"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	cargo := fakeProvider{name: "cargo", dirs: []string{dir}}
+	advisories := []Advisory{{ID: "RUSTSEC-1", Score: 7.5}}
+	log := ToSARIF([]Finding{
+		{Package: compulsive.Package{Provider: cargo, Name: "ripgrep", Version: "13.0.0", Binaries: []string{"rg"}}, Advisories: advisories},
+		{Package: compulsive.Package{Provider: cargo, Name: "serde", Version: "1.0.0"}, Advisories: advisories},
+	})
+	results := log.Runs[0].Results
+	if len(results) != 2 || len(log.Runs[0].Tool.Driver.Rules) != 1 {
+		t.Fatalf("got %+v", log.Runs[0])
+	}
+	loc := results[0].Locations[0]
+	if loc.PhysicalLocation == nil {
+		t.Fatal("expected a physical location")
+	}
+	u, err := url.Parse(loc.PhysicalLocation.ArtifactLocation.URI)
+	if err != nil || u.Scheme != "file" || filepath.FromSlash(u.Path) != filepath.Join(dir, "rg") {
+		t.Errorf("got %q, %v", loc.PhysicalLocation.ArtifactLocation.URI, err)
+	}
+	if loc.LogicalLocations[0].FullyQualifiedName != "cargo/ripgrep" {
+		t.Errorf("got %+v", loc.LogicalLocations)
+	}
+	if loc := results[1].Locations[0]; loc.PhysicalLocation != nil || results[1].Level != "error" {
+		t.Errorf("got %+v", results[1])
+	}
+}
@@ -0,0 +1,296 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	DefaultEndpoint = "https://api.osv.dev"
+	// osvBatchSize is the maximum number of queries of a batch request.
+	osvBatchSize   = 1000
+	osvConcurrency = 8
+)
+
+type (
+	// Source gives the advisories affecting a package version.
+	Source interface {
+		Query(ecosystem, name, version string) ([]Vulnerability, error)
+	}
+
+	// BatchSource is implemented by sources able to answer many queries at
+	// once, Prefetch being given the queries about to be made.
+	BatchSource interface {
+		Prefetch(queries []Query) error
+	}
+)
+
+// DirSource serves advisories from a local OSV dump, JSON files being
+// looked up recursively, e.g. the extracted all.zip of each ecosystem.
+type DirSource struct {
+	advisories map[string][]Vulnerability
+}
+
+func advisoryKey(ecosystem, name string) string {
+	return ecosystem + "\x00" + normalizeName(ecosystem, name)
+}
+
+// NewDirSource loads the advisories stored in dir.
+func NewDirSource(dir string) (*DirSource, error) {
+	src := &DirSource{advisories: make(map[string][]Vulnerability)}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+		raw, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var vuln Vulnerability
+		if err := json.Unmarshal(raw, &vuln); err != nil || vuln.ID == "" || len(vuln.Affected) == 0 {
+			log.Printf("skipping %s: not an OSV advisory", path)
+			return nil
+		}
+		seen := make(map[string]bool)
+		for _, it := range vuln.Affected {
+			key := advisoryKey(it.Package.Ecosystem, it.Package.Name)
+			if !seen[key] {
+				seen[key] = true
+				src.advisories[key] = append(src.advisories[key], vuln)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return src, nil
+}
+
+func (s *DirSource) Query(ecosystem, name, version string) ([]Vulnerability, error) {
+	var vulns []Vulnerability
+	for _, it := range s.advisories[advisoryKey(ecosystem, name)] {
+		if ok, _ := it.Matches(ecosystem, name, version); ok {
+			vulns = append(vulns, it)
+		}
+	}
+	return vulns, nil
+}
+
+// APISource queries an OSV compatible API, packages being looked up in
+// batches and advisories fetched once.
+type APISource struct {
+	Endpoint string
+	Client   *http.Client
+
+	mu      sync.Mutex
+	results map[string][]Vulnerability
+	vulns   map[string]Vulnerability
+}
+
+type (
+	osvQuery struct {
+		Version string `json:"version"`
+		Package struct {
+			Name      string `json:"name"`
+			Ecosystem string `json:"ecosystem"`
+		} `json:"package"`
+		PageToken string `json:"page_token,omitempty"`
+	}
+
+	osvBatchResponse struct {
+		Results []struct {
+			Vulns []struct {
+				ID string `json:"id"`
+			} `json:"vulns"`
+			NextPageToken string `json:"next_page_token"`
+		} `json:"results"`
+	}
+)
+
+func queryKey(ecosystem, name, version string) string {
+	return advisoryKey(ecosystem, name) + "\x00" + version
+}
+
+func (s *APISource) client() *http.Client {
+	if s.Client == nil {
+		return http.DefaultClient
+	}
+	return s.Client
+}
+
+func (s *APISource) endpoint(path string) string {
+	return strings.TrimRight(s.Endpoint, "/") + path
+}
+
+func (s *APISource) decode(resp *http.Response, err error, payload interface{}) error {
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(payload)
+}
+
+// queryBatch gives the identifiers of the advisories affecting each query,
+// following the pages of the results.
+func (s *APISource) queryBatch(queries []osvQuery) ([][]string, error) {
+	ids := make([][]string, len(queries))
+	pending := make([]int, len(queries))
+	for i := range pending {
+		pending[i] = i
+	}
+	for len(pending) > 0 {
+		var batch struct {
+			Queries []osvQuery `json:"queries"`
+		}
+		for _, it := range pending {
+			batch.Queries = append(batch.Queries, queries[it])
+		}
+		body, err := json.Marshal(batch)
+		if err != nil {
+			return nil, err
+		}
+		var payload osvBatchResponse
+		resp, err := s.client().Post(s.endpoint("/v1/querybatch"), "application/json", bytes.NewReader(body))
+		if err := s.decode(resp, err, &payload); err != nil {
+			return nil, err
+		}
+		if len(payload.Results) != len(pending) {
+			return nil, fmt.Errorf("got %d results for %d queries", len(payload.Results), len(pending))
+		}
+		var next []int
+		for i, it := range payload.Results {
+			for _, vuln := range it.Vulns {
+				ids[pending[i]] = append(ids[pending[i]], vuln.ID)
+			}
+			if it.NextPageToken != "" {
+				queries[pending[i]].PageToken = it.NextPageToken
+				next = append(next, pending[i])
+			}
+		}
+		pending = next
+	}
+	return ids, nil
+}
+
+// fetchVulns fetches the advisories not known yet, the batch endpoint only
+// giving their identifiers.
+func (s *APISource) fetchVulns(ids []string) error {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, osvConcurrency)
+	errs := make([]error, len(ids))
+	for i, id := range ids {
+		wg.Add(1)
+		go func(i int, id string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			var vuln Vulnerability
+			resp, err := s.client().Get(s.endpoint("/v1/vulns/" + url.PathEscape(id)))
+			if errs[i] = s.decode(resp, err, &vuln); errs[i] != nil {
+				return
+			}
+			s.mu.Lock()
+			s.vulns[id] = vuln
+			s.mu.Unlock()
+		}(i, id)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *APISource) Prefetch(queries []Query) error {
+	s.mu.Lock()
+	if s.results == nil {
+		s.results = make(map[string][]Vulnerability)
+		s.vulns = make(map[string]Vulnerability)
+	}
+	var keys []string
+	var batch []osvQuery
+	seen := make(map[string]bool)
+	for _, it := range queries {
+		key := queryKey(it.Ecosystem, it.Name, it.Version)
+		if _, ok := s.results[key]; ok || seen[key] {
+			continue
+		}
+		seen[key] = true
+		var query osvQuery
+		query.Version = it.Version
+		query.Package.Name = it.Name
+		query.Package.Ecosystem = it.Ecosystem
+		keys = append(keys, key)
+		batch = append(batch, query)
+	}
+	s.mu.Unlock()
+	for start := 0; start < len(batch); start += osvBatchSize {
+		end := start + osvBatchSize
+		if end > len(batch) {
+			end = len(batch)
+		}
+		ids, err := s.queryBatch(batch[start:end])
+		if err != nil {
+			return err
+		}
+		var missing []string
+		fetching := make(map[string]bool)
+		s.mu.Lock()
+		for _, list := range ids {
+			for _, id := range list {
+				if _, ok := s.vulns[id]; !ok && !fetching[id] {
+					fetching[id] = true
+					missing = append(missing, id)
+				}
+			}
+		}
+		s.mu.Unlock()
+		if err := s.fetchVulns(missing); err != nil {
+			return err
+		}
+		s.mu.Lock()
+		for i, list := range ids {
+			vulns := []Vulnerability{}
+			for _, id := range list {
+				vulns = append(vulns, s.vulns[id])
+			}
+			s.results[keys[start+i]] = vulns
+		}
+		s.mu.Unlock()
+	}
+	return nil
+}
+
+func (s *APISource) Query(ecosystem, name, version string) ([]Vulnerability, error) {
+	key := queryKey(ecosystem, name, version)
+	s.mu.Lock()
+	vulns, ok := s.results[key]
+	s.mu.Unlock()
+	if ok {
+		return vulns, nil
+	}
+	if err := s.Prefetch([]Query{{Ecosystem: ecosystem, Name: name, Version: version}}); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.results[key], nil
+}
@@ -0,0 +1,102 @@
+package audit
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestAPISourceBatch(t *testing.T) {
+	batches, fetched := 0, 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/querybatch":
+			batches++
+			var batch struct {
+				Queries []osvQuery `json:"queries"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+				t.Fatal(err)
+			}
+			var results []string
+			for _, it := range batch.Queries {
+				switch {
+				case it.Package.Name == "demo" && it.PageToken == "":
+					results = append(results, `{"vulns":[{"id":"RUSTSEC-1"}],"next_page_token":"next"}`)
+				case it.Package.Name == "demo":
+					results = append(results, `{"vulns":[{"id":"RUSTSEC-2"}]}`)
+				case it.Package.Name == "other":
+					results = append(results, `{"vulns":[{"id":"RUSTSEC-2"}]}`)
+				default:
+					results = append(results, `{}`)
+				}
+			}
+			raw := `{"results":[`
+			for i, it := range results {
+				if i > 0 {
+					raw += ","
+				}
+				raw += it
+			}
+			w.Write([]byte(raw + "]}"))
+		case "/v1/vulns/RUSTSEC-1", "/v1/vulns/RUSTSEC-2":
+			fetched++
+			w.Write([]byte(`{"id":"` + filepath.Base(r.URL.Path) + `"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	src := &APISource{Endpoint: srv.URL + "/"}
+	err := src.Prefetch([]Query{
+		{Ecosystem: "crates.io", Name: "demo", Version: "1.0.0"},
+		{Ecosystem: "crates.io", Name: "other", Version: "1.0.0"},
+		{Ecosystem: "crates.io", Name: "safe", Version: "1.0.0"},
+		{Ecosystem: "crates.io", Name: "demo", Version: "1.0.0"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if batches != 2 || fetched != 2 {
+		t.Errorf("made %d batch requests and fetched %d advisories", batches, fetched)
+	}
+	vulns, err := src.Query("crates.io", "demo", "1.0.0")
+	if err != nil || len(vulns) != 2 || vulns[0].ID != "RUSTSEC-1" || vulns[1].ID != "RUSTSEC-2" {
+		t.Errorf("got %v, %v", vulns, err)
+	}
+	if vulns, err := src.Query("crates.io", "safe", "1.0.0"); err != nil || len(vulns) != 0 {
+		t.Errorf("got %v, %v", vulns, err)
+	}
+	if batches != 2 {
+		t.Errorf("prefetched queries were sent again")
+	}
+	if _, err := src.Query("crates.io", "late", "1.0.0"); err != nil || batches != 3 {
+		t.Errorf("got %v after %d batches", err, batches)
+	}
+}
+
+func TestDirSourceSkipsOtherFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"RUSTSEC-1.json": `{"id":"RUSTSEC-1","affected":[{"package":{"ecosystem":"crates.io","name":"demo"},"versions":["1.0.0"]}]}`,
+		"manifest.json":  `{"files":["RUSTSEC-1.json"]}`,
+		"broken.json":    `{`,
+		"README.md":      `not json`,
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	src, err := NewDirSource(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vulns, err := src.Query("crates.io", "demo", "1.0.0"); err != nil || len(vulns) != 1 {
+		t.Errorf("got %v, %v", vulns, err)
+	}
+}
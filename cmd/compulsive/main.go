@@ -1,15 +1,23 @@
 package main
 
 import (
+	"bufio"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/casimir/compulsive"
+	"github.com/casimir/compulsive/audit"
+	"github.com/casimir/compulsive/config"
 	"github.com/casimir/compulsive/index"
 	"github.com/casimir/compulsive/providers"
+	"github.com/casimir/compulsive/upgrade"
 )
 
 type (
@@ -19,25 +27,57 @@ type (
 	}
 
 	options struct {
+		config   config.Config
 		all      bool
+		auto     bool
+		jobs     int
+		json     bool
+		project  bool
+		root     string
 		provider string
+		sarif    bool
+		security bool
+		since    string
+		smoke    string
 		sync     bool
+		until    string
 	}
 )
 
 var (
 	commandMap = map[string]command{
-		"info":      {"\tprint detailed information about one or more packages", runInfoPackage},
-		"packages":  {"list packages (default)", runListPackages},
-		"providers": {"list providers", runListProviders},
+		"apply":          {"\texecute the upgrades of a plan file", runApply},
+		"audit":          {"\tprint installed packages affected by known vulnerabilities", runAudit},
+		"commands":       {"print the update commands of outdated packages, in execution order", runCommands},
+		"conflicts":      {"print binaries installed several times and the copies shadowed in PATH", runConflicts},
+		"history":        {"print the upgrades executed so far", runHistory},
+		"info":           {"\tprint detailed information about one or more packages", runInfoPackage},
+		"owns":           {"\tprint the package providing a command found in PATH", runOwns},
+		"packages":       {"list packages (default)", runListPackages},
+		"plan":           {"\twrite the upgrades of outdated packages to a plan file", runPlan},
+		"providers":      {"list providers", runListProviders},
+		"rollback":       {"restore the version of a package saved by safe-upgrade", runRollback},
+		"safe-upgrade":   {"back up package binaries then upgrade them", runSafeUpgrade},
+		"upgrade":        {"upgrade outdated packages as allowed by the policy", runUpgrade},
+		"verify":         {"check that the binaries of installed packages are usable", runVerify},
+		"verify-upgrade": {"upgrade packages and check that their version actually changed", runVerifyUpgrade},
 	}
 	cliOpts options
 )
 
 func init() {
 	flag.BoolVar(&cliOpts.all, "a", false, "include up-to-date packages/unavailable providers")
+	flag.BoolVar(&cliOpts.auto, "auto", false, "upgrade without asking, deferring the updates the policy wants confirmed")
+	flag.IntVar(&cliOpts.jobs, "j", 1, "upgrade up to `n` providers at the same time")
+	flag.BoolVar(&cliOpts.json, "json", false, "print the output as JSON")
+	flag.BoolVar(&cliOpts.sarif, "sarif", false, "print audit results as SARIF")
+	flag.BoolVar(&cliOpts.security, "S", false, "order outdated packages by security impact")
+	flag.StringVar(&cliOpts.since, "since", "", "only include history entries from this `date` (YYYY-MM-DD)")
+	flag.StringVar(&cliOpts.until, "until", "", "only include history entries before this `date` (YYYY-MM-DD)")
+	flag.BoolVar(&cliOpts.project, "P", false, "work on the dependencies of the project in the current directory")
 	flag.StringVar(&cliOpts.provider, "p", "", "apply the rommand for this `provider` only")
 	flag.BoolVar(&cliOpts.sync, "s", false, "sync providers before listing packages")
+	flag.StringVar(&cliOpts.smoke, "t", "", "run binaries with these `arguments` when verifying them (e.g. --version)")
 }
 
 func runListProviders(opts options, _ ...string) error {
@@ -79,6 +119,430 @@ func runInfoPackage(opts options, args ...string) error {
 	return nil
 }
 
+func buildIndex(opts options) (index.Index, error) {
+	if opts.provider != "" {
+		if err := providers.Check(opts.provider); err != nil {
+			return nil, err
+		}
+		return index.NewFor([]string{opts.provider}, opts.sync)
+	}
+	return index.New(opts.sync)
+}
+
+func runOwns(opts options, args ...string) error {
+	if len(args) != 1 {
+		return errors.New("expected a command name")
+	}
+	path, target, err := index.ResolveBinary(args[0])
+	if err != nil {
+		return err
+	}
+	idx, err := buildIndex(opts)
+	if err != nil {
+		return fmt.Errorf("could not build index: %s", err)
+	}
+	owners := idx.FindBinaryOwners(path, target)
+	if len(owners) == 0 {
+		return compulsive.ErrBinaryNotOwned
+	}
+	fmt.Println(path)
+	for _, it := range owners {
+		fmt.Printf("%c %s\n", it.State, compulsive.FmtPkgLine(it))
+	}
+	return nil
+}
+
+func runConflicts(opts options, _ ...string) error {
+	idx, err := buildIndex(opts)
+	if err != nil {
+		return fmt.Errorf("could not build index: %s", err)
+	}
+	for _, conflict := range idx.FindConflicts() {
+		fmt.Println(conflict.Binary)
+		for i, install := range conflict.Installs {
+			marker := " "
+			if i == 0 {
+				marker = "*"
+			}
+			var owners []string
+			for _, it := range install.Owners {
+				owners = append(owners, compulsive.FmtPkgLine(it))
+			}
+			if len(owners) == 0 {
+				owners = append(owners, "unmanaged")
+			}
+			fmt.Printf("  %s %s: %s\n", marker, install.Path, strings.Join(owners, ", "))
+		}
+		for _, it := range conflict.Unreachable {
+			fmt.Printf("    not in PATH: %s\n", compulsive.FmtPkgLine(it))
+		}
+		for _, it := range conflict.Recommendations() {
+			fmt.Printf("  → %s\n", it)
+		}
+	}
+	return nil
+}
+
+func runVerify(opts options, _ ...string) error {
+	idx, err := buildIndex(opts)
+	if err != nil {
+		return fmt.Errorf("could not build index: %s", err)
+	}
+	verifyOpts := index.VerifyOptions{SmokeArgs: strings.Fields(opts.smoke)}
+	for _, pvd := range providers.ListAvailable() {
+		for _, it := range idx.ListProviderPackages(pvd.Name()) {
+			issues := index.VerifyPackage(&it, verifyOpts)
+			if len(issues) == 0 && !opts.all {
+				continue
+			}
+			fmt.Printf("%c %s\n", it.State, compulsive.FmtPkgLine(it))
+			for _, issue := range issues {
+				fmt.Printf("    %s: %s\n", issue.Binary, issue.Err)
+			}
+		}
+	}
+	return nil
+}
+
+// selectOutdated gives the outdated packages of the index, or the packages
+// matching the given names if any.
+func selectOutdated(idx index.Index, names []string) ([]compulsive.Package, error) {
+	var pkgs []compulsive.Package
+	if len(names) == 0 {
+		for _, pvd := range providers.ListAvailable() {
+			for _, it := range idx.ListProviderPackages(pvd.Name()) {
+				if it.State == compulsive.StateOutdated {
+					pkgs = append(pkgs, it)
+				}
+			}
+		}
+		return pkgs, nil
+	}
+	for _, name := range names {
+		if !compulsive.IsPackageName(name) {
+			return nil, compulsive.ErrPackageName
+		}
+		parts := strings.SplitN(name, "/", 2)
+		pvd, ok := idx.FindProviderByName(parts[0])
+		if !ok {
+			return nil, fmt.Errorf("%s: %s", name, compulsive.ErrPackageNotFound)
+		}
+		pkg, ok := idx[pvd][parts[1]]
+		if !ok {
+			return nil, fmt.Errorf("%s: %s", name, compulsive.ErrPackageNotFound)
+		}
+		pkgs = append(pkgs, pkg)
+	}
+	return pkgs, nil
+}
+
+func runCommands(opts options, args ...string) error {
+	idx, err := buildIndex(opts)
+	if err != nil {
+		return fmt.Errorf("could not build index: %s", err)
+	}
+	pkgs, err := selectOutdated(idx, args)
+	if err != nil {
+		return err
+	}
+	for _, step := range upgrade.NewPlan(pkgs).Steps {
+		fmt.Println(step.Command)
+	}
+	return nil
+}
+
+func runPlan(opts options, args ...string) error {
+	if len(args) < 1 {
+		return errors.New("expected a plan file")
+	}
+	idx, err := buildIndex(opts)
+	if err != nil {
+		return fmt.Errorf("could not build index: %s", err)
+	}
+	pkgs, err := selectOutdated(idx, args[1:])
+	if err != nil {
+		return err
+	}
+	plan := upgrade.NewPlan(pkgs)
+	plan.Project = opts.root
+	for _, step := range plan.Steps {
+		for _, it := range step.Transitions {
+			fmt.Printf("%s/%s (%s → %s)\n", it.Provider, it.Package, it.From, it.To)
+		}
+	}
+	return plan.Write(args[0])
+}
+
+func runApply(opts options, args ...string) error {
+	if len(args) != 1 {
+		return errors.New("expected a plan file")
+	}
+	plan, err := upgrade.ReadPlan(args[0])
+	if err != nil {
+		return err
+	}
+	switch {
+	case plan.Project != "":
+		providers.UseProject(plan.Project)
+	case opts.project:
+		return errors.New("plan was made for the installed packages, not a project")
+	}
+	idx, err := index.NewFor(plan.Providers(), opts.sync)
+	if err != nil {
+		return fmt.Errorf("could not build index: %s", err)
+	}
+	if err := plan.CheckDrift(idx); err != nil {
+		return err
+	}
+	for _, step := range plan.Steps {
+		fmt.Println("$ " + step.Command)
+		if err := upgrade.RunStep(step, execOptions(opts), os.Stdout, os.Stderr); err != nil {
+			return fmt.Errorf("%s: %s", step.Provider, err)
+		}
+	}
+	return nil
+}
+
+func runVerifyUpgrade(opts options, args ...string) error {
+	idx, err := buildIndex(opts)
+	if err != nil {
+		return fmt.Errorf("could not build index: %s", err)
+	}
+	pkgs, err := selectOutdated(idx, args)
+	if err != nil {
+		return err
+	}
+	results, err := execute(opts, pkgs)
+	if err != nil {
+		return err
+	}
+	return printResults(results)
+}
+
+// execOptions gives the options commands are run with, as configured for
+// this invocation.
+func execOptions(opts options) upgrade.ExecOptions {
+	return upgrade.ExecOptions{
+		Concurrency: opts.jobs,
+		Escalation: upgrade.Escalation{
+			Strategy: opts.config.Privilege.Strategy,
+			User:     opts.config.Privilege.User,
+		},
+	}
+}
+
+// execute upgrades packages, keeping the full output of each provider in a
+// log directory dedicated to this run.
+func execute(opts options, pkgs []compulsive.Package) ([]upgrade.Result, error) {
+	execOpts := execOptions(opts)
+	if dir, err := compulsive.DataDir(); err == nil {
+		execOpts.LogDir = filepath.Join(dir, "logs", time.Now().Format("20060102-150405"))
+	}
+	results, err := upgrade.Execute(pkgs, os.Stdout, execOpts)
+	if execOpts.LogDir != "" && len(pkgs) > 0 {
+		fmt.Println("logs written to " + execOpts.LogDir)
+	}
+	return results, err
+}
+
+func printResults(results []upgrade.Result) error {
+	fmt.Println("")
+	failed := false
+	for _, it := range results {
+		line := fmt.Sprintf("%s/%s: %s", it.Package.Provider.Name(), it.Package.Name, it.Outcome)
+		switch it.Outcome {
+		case upgrade.OutcomeUpgraded:
+			line += fmt.Sprintf(" (%s → %s)", it.Package.Version, it.NewVersion)
+		case upgrade.OutcomeUnchanged:
+			line += fmt.Sprintf(" (%s)", it.Package.Version)
+		case upgrade.OutcomeFailed:
+			failed = true
+			line += fmt.Sprintf(" (%s)", it.Err)
+		}
+		fmt.Println(line)
+		if it.Outcome != upgrade.OutcomeUpgraded && it.Output != "" {
+			for _, outLine := range strings.Split(strings.TrimRight(it.Output, "\n"), "\n") {
+				fmt.Println("    | " + outLine)
+			}
+		}
+	}
+	if failed {
+		return errors.New("some upgrades failed")
+	}
+	return nil
+}
+
+// stdin is shared by the prompts so that input buffered by one is not lost
+// to the next.
+var stdin = bufio.NewReader(os.Stdin)
+
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer, _ := stdin.ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+func runUpgrade(opts options, args ...string) error {
+	idx, err := buildIndex(opts)
+	if err != nil {
+		return fmt.Errorf("could not build index: %s", err)
+	}
+	pkgs, err := selectOutdated(idx, args)
+	if err != nil {
+		return err
+	}
+	var applied []compulsive.Package
+	var deferred []upgrade.Decision
+	now := time.Now()
+	for _, it := range pkgs {
+		decision := upgrade.Evaluate(opts.config.Policy, it, now)
+		if decision.Action == upgrade.ActionConfirm {
+			if opts.auto {
+				decision.Action = upgrade.ActionSkip
+				decision.Reason += ", confirmation needed"
+			} else if confirm(fmt.Sprintf("upgrade %s (%s)?", compulsive.FmtPkgLine(it), decision.Reason)) {
+				decision.Action = upgrade.ActionApply
+			} else {
+				decision.Action = upgrade.ActionSkip
+				decision.Reason += ", declined"
+			}
+		}
+		if decision.Action == upgrade.ActionApply {
+			applied = append(applied, it)
+		} else {
+			deferred = append(deferred, decision)
+		}
+	}
+	var results []upgrade.Result
+	if len(applied) > 0 {
+		if results, err = execute(opts, applied); err != nil {
+			return err
+		}
+	}
+	if len(deferred) > 0 {
+		fmt.Println("")
+		for _, it := range deferred {
+			fmt.Printf("%s: deferred (%s)\n", compulsive.FmtPkgLine(it.Package), it.Reason)
+		}
+	}
+	if len(results) == 0 {
+		return nil
+	}
+	return printResults(results)
+}
+
+func runSafeUpgrade(opts options, args ...string) error {
+	idx, err := buildIndex(opts)
+	if err != nil {
+		return fmt.Errorf("could not build index: %s", err)
+	}
+	pkgs, err := selectOutdated(idx, args)
+	if err != nil {
+		return err
+	}
+	var saved []compulsive.Package
+	for _, it := range pkgs {
+		if _, err := upgrade.BackupPackage(it); err != nil {
+			fmt.Fprintf(os.Stderr, "skipping %s/%s: %s\n", it.Provider.Name(), it.Name, err)
+			continue
+		}
+		saved = append(saved, it)
+	}
+	results, err := execute(opts, saved)
+	if err != nil {
+		return err
+	}
+	return printResults(results)
+}
+
+func runRollback(opts options, args ...string) error {
+	if len(args) != 1 || !compulsive.IsPackageName(args[0]) {
+		return compulsive.ErrPackageName
+	}
+	parts := strings.SplitN(args[0], "/", 2)
+	idx, err := index.NewFor([]string{parts[0]}, false)
+	if err != nil {
+		return fmt.Errorf("could not build index: %s", err)
+	}
+	pkgs, err := selectOutdated(idx, args)
+	if err != nil {
+		return err
+	}
+	pkg := pkgs[0]
+	backup, err := upgrade.LatestBackup(pkg)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("rolling back %s/%s (%s → %s)\n", pkg.Provider.Name(), pkg.Name, pkg.Version, backup.Version)
+	if command := backup.RollbackCommand(pkg); command != "" {
+		fmt.Println("$ " + command)
+		step := upgrade.Step{
+			Provider:   pkg.Provider.Name(),
+			Command:    command,
+			Privileged: upgrade.NeedsRoot(pkg.Provider, compulsive.OpUpdate),
+			Transitions: []upgrade.Transition{{
+				Provider: pkg.Provider.Name(),
+				Package:  pkg.Name,
+				From:     pkg.Version,
+				To:       backup.Version,
+			}},
+		}
+		return upgrade.RunStep(step, execOptions(opts), os.Stdout, os.Stderr)
+	}
+	return backup.Restore()
+}
+
+func parseDate(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", value, time.Local)
+}
+
+func runHistory(opts options, args ...string) error {
+	if len(args) > 1 {
+		return errors.New("expected at most one package")
+	}
+	filter := upgrade.HistoryFilter{Provider: opts.provider}
+	if len(args) == 1 {
+		filter.Package = args[0]
+	}
+	var err error
+	if filter.Since, err = parseDate(opts.since); err != nil {
+		return fmt.Errorf("invalid date: %s", err)
+	}
+	if filter.Until, err = parseDate(opts.until); err != nil {
+		return fmt.Errorf("invalid date: %s", err)
+	}
+	entries, err := upgrade.ReadHistory(filter)
+	if err != nil {
+		return err
+	}
+	if opts.json {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if entries == nil {
+			entries = []upgrade.Entry{}
+		}
+		return enc.Encode(entries)
+	}
+	for _, entry := range entries {
+		status := "ok"
+		if entry.ExitStatus != 0 {
+			status = fmt.Sprintf("exit %d", entry.ExitStatus)
+		}
+		fmt.Printf("%s %s %s (%.1fs, %s)\n", entry.Time.Local().Format("2006-01-02 15:04"), entry.User, entry.Provider, entry.Duration, status)
+		for _, it := range entry.Transitions {
+			fmt.Printf("    %s/%s (%s → %s)\n", it.Provider, it.Package, it.From, it.To)
+		}
+	}
+	return nil
+}
+
 func runProvider(opts options, _ ...string) error {
 	if err := providers.Check(opts.provider); err != nil {
 		return err
@@ -98,7 +562,94 @@ func runProvider(opts options, _ ...string) error {
 	return nil
 }
 
+func osvSource(opts options) (audit.Source, error) {
+	if opts.config.OSV.Dir != "" {
+		return audit.NewDirSource(opts.config.OSV.Dir)
+	}
+	endpoint := opts.config.OSV.Endpoint
+	if endpoint == "" {
+		endpoint = audit.DefaultEndpoint
+	}
+	return &audit.APISource{Endpoint: endpoint}, nil
+}
+
+func runAudit(opts options, _ ...string) error {
+	src, err := osvSource(opts)
+	if err != nil {
+		return fmt.Errorf("could not load advisories: %s", err)
+	}
+	idx, err := buildIndex(opts)
+	if err != nil {
+		return fmt.Errorf("could not build index: %s", err)
+	}
+	findings := audit.Audit(src, idx)
+	if opts.sarif {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(audit.ToSARIF(findings))
+	}
+	for _, finding := range findings {
+		fmt.Printf("%c %s\n", finding.Package.State, compulsive.FmtPkgLine(finding.Package))
+		for _, it := range finding.Advisories {
+			line := fmt.Sprintf("    %s %s (%.1f)", it.ID, audit.SeverityRating(it.Score), it.Score)
+			if it.Fixed != "" {
+				line += " fixed in " + it.Fixed
+			}
+			if it.Summary != "" {
+				line += ": " + it.Summary
+			}
+			fmt.Println(line)
+		}
+	}
+	return nil
+}
+
+// listBySecurity prints outdated packages, the ones affected by the most
+// severe advisories first.
+func listBySecurity(opts options, idx index.Index) error {
+	src, err := osvSource(opts)
+	if err != nil {
+		return fmt.Errorf("could not load advisories: %s", err)
+	}
+	var pkgs []compulsive.Package
+	for _, pvd := range providers.ListAvailable() {
+		for _, it := range idx.ListProviderPackages(pvd.Name()) {
+			if opts.all || it.State == compulsive.StateOutdated {
+				pkgs = append(pkgs, it)
+			}
+		}
+	}
+	audit.Prefetch(src, pkgs)
+	var findings []audit.Finding
+	for _, it := range pkgs {
+		finding, err := audit.CheckPackage(src, it)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to audit %s/%s: %s\n", it.Provider.Name(), it.Name, err)
+		}
+		findings = append(findings, finding)
+	}
+	audit.SortFindings(findings)
+	for _, it := range findings {
+		line := compulsive.FmtPkgLine(it.Package)
+		if opts.all {
+			line = fmt.Sprintf("%c %s", it.Package.State, line)
+		}
+		if len(it.Advisories) > 0 {
+			line += fmt.Sprintf(" [%d advisories, %s]", len(it.Advisories), audit.SeverityRating(it.Score()))
+		}
+		fmt.Println(line)
+	}
+	return nil
+}
+
 func runListPackages(opts options, args ...string) error {
+	if opts.security {
+		idx, err := buildIndex(opts)
+		if err != nil {
+			return fmt.Errorf("could not build index: %s", err)
+		}
+		return listBySecurity(opts, idx)
+	}
 	if opts.provider != "" {
 		return runProvider(opts)
 	}
@@ -148,7 +699,31 @@ func main() {
 		args = args[1:]
 	}
 
-	var err error
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(1)
+	}
+	cliOpts.config = cfg
+	providers.MinReleaseAge = time.Duration(cfg.CooldownDays) * 24 * time.Hour
+	providers.CargoRoots = cfg.Cargo.Roots
+	providers.CargoPrereleases = cfg.Cargo.Prereleases
+	providers.CargoLocalRegistry = cfg.Cargo.LocalRegistry
+	providers.CargoBinstall = cfg.Cargo.Binstall
+	if cfg.Cargo.Index != "" {
+		providers.CargoIndex = cfg.Cargo.Index
+	}
+
+	if cliOpts.project {
+		root, err := providers.FindProjectRoot(".")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err)
+			os.Exit(1)
+		}
+		providers.UseProject(root)
+		cliOpts.root = root
+	}
+
 	if command, ok := commandMap[commandName]; ok {
 		err = command.runFunc(cliOpts, args...)
 	} else if commandName == "help" {
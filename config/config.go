@@ -0,0 +1,129 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/casimir/compulsive/audit"
+)
+
+type (
+	// Privilege tells how to run the commands needing root privileges.
+	Privilege struct {
+		// Strategy is one of "refuse", "sudo", "doas" or "user".
+		Strategy string `json:"strategy"`
+		// User is the account commands are run as with the "user" strategy.
+		User string `json:"user"`
+	}
+
+	// Rule tells what to do with the updates it matches, empty fields
+	// matching anything.
+	Rule struct {
+		// Providers restricts the rule to these providers.
+		Providers []string `json:"providers"`
+		// Packages restricts the rule to packages matching these
+		// provider/name patterns.
+		Packages []string `json:"packages"`
+		// Change is the version bump matched: "major", "minor" or "patch".
+		Change string `json:"change"`
+		// Action is one of "apply", "confirm" or "skip".
+		Action string `json:"action"`
+		// MinAgeDays defers updates released more recently than that.
+		MinAgeDays int `json:"min_age_days"`
+	}
+
+	// Policy decides which updates can be applied automatically, the first
+	// matching rule winning.
+	Policy struct {
+		Rules []Rule `json:"rules"`
+		// Default is the action used when no rule matches.
+		Default string `json:"default"`
+		// MinAgeDays defers any update released more recently than that.
+		MinAgeDays int `json:"min_age_days"`
+	}
+
+	// Cargo holds the settings of the cargo provider.
+	Cargo struct {
+		// Roots are install roots scanned in addition to the ones cargo
+		// is configured with, e.g. the ones used with cargo install --root.
+		Roots []string `json:"roots"`
+		// Index is the sparse registry index crates.io packages are
+		// resolved with, e.g. sparse+https://mirror.example.com/index/.
+		Index string `json:"index"`
+		// Prereleases allows prereleases to be proposed as updates.
+		Prereleases bool `json:"prereleases"`
+		// LocalRegistry is a local registry, cloned index or vendor
+		// directory versions are resolved from, without network access.
+		LocalRegistry string `json:"local_registry"`
+		// Binstall is "auto" to update crates from prebuilt binaries when
+		// cargo binstall is installed, "never" to always build them.
+		Binstall string `json:"binstall"`
+	}
+
+	// OSV tells where vulnerability advisories are read from.
+	OSV struct {
+		// Dir is a local OSV dump, used instead of the API when set.
+		Dir string `json:"dir"`
+		// Endpoint is the URL of an OSV compatible API.
+		Endpoint string `json:"endpoint"`
+	}
+
+	Config struct {
+		// CooldownDays is the age a release must reach before being
+		// considered available.
+		CooldownDays int       `json:"cooldown_days"`
+		Cargo        Cargo     `json:"cargo"`
+		OSV          OSV       `json:"osv"`
+		Policy       Policy    `json:"policy"`
+		Privilege    Privilege `json:"privilege"`
+	}
+)
+
+// Default gives the configuration used when no configuration file exists.
+func Default() Config {
+	return Config{
+		Cargo:     Cargo{Binstall: "auto"},
+		OSV:       OSV{Endpoint: audit.DefaultEndpoint},
+		Policy:    Policy{Default: "confirm"},
+		Privilege: Privilege{Strategy: "refuse"},
+	}
+}
+
+// Path gives the location of the configuration file, which can be
+// overridden with the COMPULSIVE_CONFIG environment variable.
+func Path() (string, error) {
+	if path := os.Getenv("COMPULSIVE_CONFIG"); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "compulsive", "config.json"), nil
+}
+
+// Load reads the configuration file, missing settings keeping their default
+// value.
+func Load() (Config, error) {
+	cfg := Default()
+	path, err := Path()
+	if err != nil {
+		return cfg, err
+	}
+	raw, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	} else if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		return cfg, fmt.Errorf("invalid configuration %s: %s", path, err)
+	}
+	if cfg.Cargo.Binstall != "auto" && cfg.Cargo.Binstall != "never" {
+		return cfg, fmt.Errorf("invalid configuration %s: binstall must be \"auto\" or \"never\", got %q", path, cfg.Cargo.Binstall)
+	}
+	return cfg, nil
+}
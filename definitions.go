@@ -1,11 +1,23 @@
 package compulsive
 
+import "time"
+
 type PackageState rune
 
 const (
 	StateUnknown  PackageState = '?'
 	StateOutdated PackageState = '+'
 	StateUpToDate PackageState = '='
+	StateBroken   PackageState = '!'
+)
+
+// Operation is an action of a provider that may need root privileges.
+// Only the commands compulsive executes can be escalated, syncs run as the
+// current user.
+type Operation string
+
+const (
+	OpUpdate Operation = "update"
 )
 
 type (
@@ -18,6 +30,10 @@ type (
 		State       PackageState
 		Version     string
 		NextVersion string
+		// Released is the publication date of NextVersion, zero if unknown.
+		Released time.Time
+		// Attributes holds provider specific details, shown by info.
+		Attributes map[string]string
 	}
 
 	Provider interface {
@@ -27,4 +43,47 @@ type (
 		List() ([]Package, error)
 		UpdateCommand(...Package) string
 	}
+
+	// BinaryLocator is implemented by providers knowing the directories
+	// where the binaries of their packages are installed.
+	BinaryLocator interface {
+		BinaryDirs() []string
+	}
+
+	// DependentProvider is implemented by providers whose packages must be
+	// upgraded after the ones of other providers.
+	DependentProvider interface {
+		Dependencies() []string
+	}
+
+	// SelfManagedProvider is implemented by providers whose package manager
+	// is itself one of their packages, to be upgraded before the others.
+	SelfManagedProvider interface {
+		BootstrapPackage() string
+	}
+
+	// LockingProvider is implemented by providers sharing a lock with other
+	// providers, providers holding the same key never upgrading at the same
+	// time.
+	LockingProvider interface {
+		LockKey() string
+	}
+
+	// PrivilegedProvider is implemented by providers having operations that
+	// need root privileges, e.g. when they manage a system-wide prefix.
+	PrivilegedProvider interface {
+		NeedsRoot(Operation) bool
+	}
+
+	// EcosystemProvider is implemented by providers whose packages are
+	// tracked by OSV advisories, Ecosystem giving the OSV ecosystem name.
+	EcosystemProvider interface {
+		Ecosystem() string
+	}
+
+	// VersionInstaller is implemented by providers able to install a given
+	// version of a package, downgrades included.
+	VersionInstaller interface {
+		InstallVersionCommand(pkg Package, version string) string
+	}
 )
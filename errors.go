@@ -7,8 +7,10 @@ import (
 )
 
 var (
+	ErrBinaryNotOwned      = errors.New("binary not installed by any provider")
 	ErrPackageName         = errors.New("not a package name")
 	ErrPackageNotFound     = errors.New("package not found")
+	ErrProjectNotFound     = errors.New("no project found in the current directory or its parents")
 	ErrProviderNotFound    = errors.New("provider not found")
 	ErrProviderUnavailable = errors.New("provider unavailable")
 	ErrSudoNeeded          = errors.New("sudo needed for this operation")
@@ -2,8 +2,10 @@ package compulsive
 
 import (
 	"bytes"
+	"fmt"
 	"strings"
 	"text/template"
+	"time"
 )
 
 const pkgDescTpl = `Package: {{.Provider.Name}}/{{.Name}}
@@ -11,12 +13,25 @@ Name: {{.Label}}{{if .Summary}}
 Summary: {{.Summary}}{{end}}{{if .Binaries}}
 Binaries: {{StringsJoin .Binaries ", "}}{{end}}
 Version: {{.Version}}{{if .NextVersion}}
-Available: {{.NextVersion}}{{end}}
+Available: {{.NextVersion}}{{end}}{{if not .Released.IsZero}}
+Released: {{.Released.Format "2006-01-02"}} ({{ReleaseAge .Released}}){{end}}{{range $key, $value := .Attributes}}
+{{$key}}: {{$value}}{{end}}
 `
 
+func fmtReleaseAge(released time.Time) string {
+	days := int(time.Since(released).Hours() / 24)
+	switch days {
+	case 0:
+		return "today"
+	case 1:
+		return "1 day ago"
+	}
+	return fmt.Sprintf("%d days ago", days)
+}
+
 func FmtPkgDesc(pkg Package) string {
 	t := template.New("description")
-	t.Funcs(template.FuncMap{"StringsJoin": strings.Join})
+	t.Funcs(template.FuncMap{"StringsJoin": strings.Join, "ReleaseAge": fmtReleaseAge})
 	t = template.Must(t.Parse(pkgDescTpl))
 	buf := bytes.NewBufferString("")
 	if err := t.Execute(buf, pkg); err != nil {
@@ -0,0 +1,84 @@
+package index
+
+import (
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/casimir/compulsive"
+)
+
+func binaryName(path string) string {
+	name := filepath.Base(path)
+	if runtime.GOOS == "windows" {
+		name = strings.TrimSuffix(strings.ToLower(name), ".exe")
+	}
+	return name
+}
+
+func inDirs(path string, dirs []string) bool {
+	for _, dir := range dirs {
+		if rel, err := filepath.Rel(dir, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveBinary looks up a command name through PATH and gives both the path
+// found and the path it eventually points to.
+func ResolveBinary(name string) (string, string, error) {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return "", "", err
+	}
+	if path, err = filepath.Abs(path); err != nil {
+		return "", "", err
+	}
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		target = path
+	}
+	return path, target, nil
+}
+
+// Owns tells whether pkg provides the binary installed at path. The path is
+// matched against the provider binary directories when they are known,
+// otherwise on the binary name only.
+func Owns(pkg compulsive.Package, path, target string) bool {
+	name := binaryName(path)
+	found := false
+	for _, it := range pkg.Binaries {
+		if binaryName(it) == name {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return false
+	}
+	locator, ok := pkg.Provider.(compulsive.BinaryLocator)
+	if !ok {
+		return true
+	}
+	dirs := locator.BinaryDirs()
+	return inDirs(path, dirs) || inDirs(target, dirs)
+}
+
+// FindBinaryOwners gives the packages providing the binary installed at path.
+func (idx Index) FindBinaryOwners(path, target string) []compulsive.Package {
+	var owners []compulsive.Package
+	for _, pkgs := range idx {
+		for _, pkg := range pkgs {
+			if Owns(pkg, path, target) {
+				owners = append(owners, pkg)
+			}
+		}
+	}
+	sort.Slice(owners, func(i, j int) bool {
+		return owners[i].Provider.Name()+"/"+owners[i].Name < owners[j].Provider.Name()+"/"+owners[j].Name
+	})
+	return owners
+}
@@ -0,0 +1,166 @@
+package index
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/casimir/compulsive"
+)
+
+type (
+	// BinaryInstall is a copy of a binary found in one of the PATH entries.
+	BinaryInstall struct {
+		Path   string
+		Target string
+		Owners []compulsive.Package
+	}
+
+	// Conflict gathers every copy of a binary, the first install being the
+	// one winning on PATH.
+	Conflict struct {
+		Binary      string
+		Installs    []BinaryInstall
+		Unreachable []compulsive.Package
+	}
+)
+
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return false
+	}
+	return runtime.GOOS == "windows" || info.Mode()&0111 != 0
+}
+
+// LookPathAll gives every match of a command name in PATH, in PATH order.
+// Entries resolving to the same directory, e.g. /bin linked to /usr/bin, are
+// only looked up once.
+func LookPathAll(name string) []string {
+	candidates := []string{name}
+	if runtime.GOOS == "windows" && filepath.Ext(name) == "" {
+		candidates = append(candidates, name+".exe")
+	}
+	var paths []string
+	seen := make(map[string]bool)
+	seenDirs := make(map[string]bool)
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if dir == "" {
+			continue
+		}
+		resolved, err := filepath.EvalSymlinks(dir)
+		if err != nil || seenDirs[resolved] {
+			continue
+		}
+		seenDirs[resolved] = true
+		for _, it := range candidates {
+			path, err := filepath.Abs(filepath.Join(dir, it))
+			if err != nil || seen[path] || !isExecutable(path) {
+				continue
+			}
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+func pkgID(pkg compulsive.Package) string {
+	return pkg.Provider.Name() + "/" + pkg.Name
+}
+
+// Owners gives the packages owning at least one copy of the binary.
+func (c Conflict) Owners() []compulsive.Package {
+	var owners []compulsive.Package
+	seen := make(map[string]bool)
+	for _, install := range c.Installs {
+		for _, it := range install.Owners {
+			if !seen[pkgID(it)] {
+				seen[pkgID(it)] = true
+				owners = append(owners, it)
+			}
+		}
+	}
+	return append(owners, c.Unreachable...)
+}
+
+// Recommendations suggests which copies of the binary should be removed so
+// that the one left is the one on PATH.
+func (c Conflict) Recommendations() []string {
+	if len(c.Installs) == 0 {
+		return nil
+	}
+	var advices []string
+	winner := c.Installs[0]
+	if len(winner.Owners) == 0 {
+		for _, it := range c.Owners() {
+			advices = append(advices, "remove "+pkgID(it)+" or put it before "+winner.Path+" in PATH")
+		}
+		return advices
+	}
+	kept := winner.Owners[0]
+	for _, it := range c.Owners() {
+		if pkgID(it) != pkgID(kept) {
+			advices = append(advices, "remove "+pkgID(it)+" (shadowed by "+pkgID(kept)+")")
+		}
+	}
+	for _, install := range c.Installs[1:] {
+		if len(install.Owners) == 0 {
+			advices = append(advices, "remove "+install.Path+" (shadowed by "+pkgID(kept)+")")
+		}
+	}
+	return advices
+}
+
+// FindConflicts reports binaries installed several times, either by
+// several packages or in several PATH entries.
+func (idx Index) FindConflicts() []Conflict {
+	declared := make(map[string][]compulsive.Package)
+	for _, pkgs := range idx {
+		for _, pkg := range pkgs {
+			seen := make(map[string]bool)
+			for _, it := range pkg.Binaries {
+				if name := binaryName(it); !seen[name] {
+					seen[name] = true
+					declared[name] = append(declared[name], pkg)
+				}
+			}
+		}
+	}
+	var conflicts []Conflict
+	for name, pkgs := range declared {
+		sort.Slice(pkgs, func(i, j int) bool {
+			return pkgID(pkgs[i]) < pkgID(pkgs[j])
+		})
+		conflict := Conflict{Binary: name}
+		reached := make(map[string]bool)
+		for _, path := range LookPathAll(name) {
+			target, err := filepath.EvalSymlinks(path)
+			if err != nil {
+				target = path
+			}
+			install := BinaryInstall{Path: path, Target: target}
+			for _, pkg := range pkgs {
+				if Owns(pkg, path, target) {
+					install.Owners = append(install.Owners, pkg)
+					reached[pkgID(pkg)] = true
+				}
+			}
+			conflict.Installs = append(conflict.Installs, install)
+		}
+		for _, pkg := range pkgs {
+			if !reached[pkgID(pkg)] {
+				conflict.Unreachable = append(conflict.Unreachable, pkg)
+			}
+		}
+		if len(conflict.Installs) > 1 || len(conflict.Owners()) > 1 {
+			conflicts = append(conflicts, conflict)
+		}
+	}
+	sort.Slice(conflicts, func(i, j int) bool {
+		return strings.ToLower(conflicts[i].Binary) < strings.ToLower(conflicts[j].Binary)
+	})
+	return conflicts
+}
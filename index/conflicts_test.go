@@ -0,0 +1,105 @@
+package index
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/casimir/compulsive"
+)
+
+type fakeProvider struct {
+	name string
+	dirs []string
+}
+
+func (p fakeProvider) Name() string                                    { return p.name }
+func (p fakeProvider) IsAvailable() bool                               { return true }
+func (p fakeProvider) Sync() error                                     { return nil }
+func (p fakeProvider) List() ([]compulsive.Package, error)             { return nil, nil }
+func (p fakeProvider) UpdateCommand(pkgs ...compulsive.Package) string { return "" }
+func (p fakeProvider) BinaryDirs() []string                            { return p.dirs }
+
+func writeExecutable(t *testing.T, dir, name string) string {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLookPathAll(t *testing.T) {
+	root := t.TempDir()
+	bin := filepath.Join(root, "bin")
+	first := writeExecutable(t, bin, "tool")
+	link := filepath.Join(root, "link")
+	if err := os.Symlink(bin, link); err != nil {
+		t.Fatal(err)
+	}
+	second := writeExecutable(t, filepath.Join(root, "other"), "tool")
+	t.Setenv("PATH", filepath.Join(root, "bin")+string(os.PathListSeparator)+link+string(os.PathListSeparator)+filepath.Join(root, "other"))
+	if got, expected := LookPathAll("tool"), []string{first, second}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestFindConflicts(t *testing.T) {
+	root := t.TempDir()
+	cargoBin := filepath.Join(root, "cargo")
+	brewBin := filepath.Join(root, "brew")
+	writeExecutable(t, cargoBin, "rg")
+	writeExecutable(t, brewBin, "rg")
+	writeExecutable(t, brewBin, "fd")
+	writeExecutable(t, filepath.Join(root, "local"), "fd")
+	t.Setenv("PATH", cargoBin+string(os.PathListSeparator)+brewBin+string(os.PathListSeparator)+filepath.Join(root, "local"))
+
+	cargo := &fakeProvider{name: "cargo", dirs: []string{cargoBin}}
+	brew := &fakeProvider{name: "brew", dirs: []string{brewBin}}
+	pip := &fakeProvider{name: "pip", dirs: []string{filepath.Join(root, "pip")}}
+	idx := Index{
+		cargo: {"ripgrep": {Provider: cargo, Name: "ripgrep", Binaries: []string{"rg"}}},
+		brew: {
+			"ripgrep": {Provider: brew, Name: "ripgrep", Binaries: []string{"rg"}},
+			"fd":      {Provider: brew, Name: "fd", Binaries: []string{"fd"}},
+		},
+		pip: {"ripgrep-bin": {Provider: pip, Name: "ripgrep-bin", Binaries: []string{"rg"}}},
+	}
+	conflicts := idx.FindConflicts()
+	if len(conflicts) != 2 || conflicts[0].Binary != "fd" || conflicts[1].Binary != "rg" {
+		t.Fatalf("got %v", conflicts)
+	}
+	expected := []string{"remove " + filepath.Join(root, "local", "fd") + " (shadowed by brew/fd)"}
+	if got := conflicts[0].Recommendations(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v", got)
+	}
+	expected = []string{
+		"remove brew/ripgrep (shadowed by cargo/ripgrep)",
+		"remove pip/ripgrep-bin (shadowed by cargo/ripgrep)",
+	}
+	if got := conflicts[1].Recommendations(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v", got)
+	}
+	if got := conflicts[1].Unreachable; len(got) != 1 || got[0].Provider != pip {
+		t.Errorf("got unreachable %v", got)
+	}
+}
+
+func TestRecommendationsUnowned(t *testing.T) {
+	brew := &fakeProvider{name: "brew"}
+	conflict := Conflict{
+		Binary: "fd",
+		Installs: []BinaryInstall{
+			{Path: "/usr/local/bin/fd"},
+			{Path: "/opt/homebrew/bin/fd", Owners: []compulsive.Package{{Provider: brew, Name: "fd"}}},
+		},
+	}
+	expected := []string{"remove brew/fd or put it before /usr/local/bin/fd in PATH"}
+	if got := conflict.Recommendations(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v", got)
+	}
+}
@@ -3,6 +3,7 @@ package index
 import (
 	"fmt"
 	"sort"
+	"time"
 
 	"github.com/casimir/compulsive"
 	"github.com/casimir/compulsive/providers"
@@ -32,6 +33,15 @@ func (idx Index) ListProviderPackages(providerName string) []compulsive.Package
 	return list
 }
 
+// cooldown marks as up to date the packages whose next version is younger
+// than providers.MinReleaseAge.
+func cooldown(pkg compulsive.Package, now time.Time) compulsive.Package {
+	if pkg.State == compulsive.StateOutdated && !pkg.Released.IsZero() && now.Sub(pkg.Released) < providers.MinReleaseAge {
+		pkg.State = compulsive.StateUpToDate
+	}
+	return pkg
+}
+
 func NewFor(names []string, sync bool) (Index, error) {
 	index := make(map[compulsive.Provider]map[string]compulsive.Package)
 	sort.Strings(names)
@@ -48,8 +58,9 @@ func NewFor(names []string, sync bool) (Index, error) {
 			if err != nil {
 				return index, err
 			}
+			now := time.Now()
 			for _, pkg := range list {
-				pvdIndex[pkg.Name] = pkg
+				pvdIndex[pkg.Name] = cooldown(pkg, now)
 			}
 			index[pvd] = pvdIndex
 		}
@@ -0,0 +1,159 @@
+package index
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/casimir/compulsive"
+)
+
+var (
+	errBinaryMissing       = errors.New("binary not found")
+	errBinaryDangling      = errors.New("dangling symlink")
+	errBinaryNotExecutable = errors.New("not executable")
+)
+
+type (
+	// VerifyOptions tunes the checks run on package binaries.
+	VerifyOptions struct {
+		SmokeArgs    []string
+		SmokeTimeout time.Duration
+	}
+
+	// BinaryIssue describes why a binary of a package is considered broken.
+	BinaryIssue struct {
+		Binary string
+		Path   string
+		Err    error
+	}
+)
+
+// LocateBinary gives the path a binary of a package is installed at, or an
+// empty string when it cannot be found. Only files and symlinks match, a
+// binary directory may hold directories named after packages.
+func LocateBinary(pkg compulsive.Package, name string) string {
+	candidates := []string{name}
+	if runtime.GOOS == "windows" && filepath.Ext(name) == "" {
+		candidates = append(candidates, name+".exe")
+	}
+	if locator, ok := pkg.Provider.(compulsive.BinaryLocator); ok {
+		for _, dir := range locator.BinaryDirs() {
+			for _, it := range candidates {
+				path := filepath.Join(dir, it)
+				if info, err := os.Lstat(path); err == nil && (info.Mode().IsRegular() || info.Mode()&os.ModeSymlink != 0) {
+					return path
+				}
+			}
+		}
+		return ""
+	}
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return ""
+	}
+	return path
+}
+
+// checkInterpreter makes sure the interpreter of a script still exists.
+func checkInterpreter(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	line, _ := bufio.NewReader(f).ReadString('\n')
+	if !strings.HasPrefix(line, "#!") {
+		return nil
+	}
+	fields := strings.Fields(line[2:])
+	if len(fields) == 0 {
+		return nil
+	}
+	interpreter := fields[0]
+	if filepath.Base(interpreter) == "env" && len(fields) > 1 {
+		if _, err := exec.LookPath(fields[1]); err != nil {
+			return fmt.Errorf("interpreter %s not found", fields[1])
+		}
+		return nil
+	}
+	if !isExecutable(interpreter) {
+		return fmt.Errorf("interpreter %s not found", interpreter)
+	}
+	return nil
+}
+
+func smokeTest(path string, opts VerifyOptions) error {
+	timeout := opts.SmokeTimeout
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	var out bytes.Buffer
+	cmd := exec.CommandContext(ctx, path, opts.SmokeArgs...)
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("smoke test timed out after %s", timeout)
+		}
+		msg := strings.TrimSpace(out.String())
+		if i := strings.IndexByte(msg, '\n'); i >= 0 {
+			msg = msg[:i]
+		}
+		return fmt.Errorf("smoke test failed: %s: %s", err, msg)
+	}
+	return nil
+}
+
+// VerifyBinary checks that the binary at path can actually be run.
+func VerifyBinary(path string, opts VerifyOptions) error {
+	if path == "" {
+		return errBinaryMissing
+	}
+	if _, err := os.Lstat(path); err != nil {
+		return errBinaryMissing
+	}
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return errBinaryDangling
+	}
+	if !isExecutable(target) {
+		return errBinaryNotExecutable
+	}
+	if err := checkInterpreter(target); err != nil {
+		return err
+	}
+	if err := checkLibraries(target); err != nil {
+		return err
+	}
+	if len(opts.SmokeArgs) > 0 {
+		return smokeTest(path, opts)
+	}
+	return nil
+}
+
+// VerifyPackage checks every binary of a package and marks it as broken
+// when at least one of them is unusable.
+func VerifyPackage(pkg *compulsive.Package, opts VerifyOptions) []BinaryIssue {
+	var issues []BinaryIssue
+	for _, it := range pkg.Binaries {
+		path := LocateBinary(*pkg, it)
+		if err := VerifyBinary(path, opts); err != nil {
+			issues = append(issues, BinaryIssue{Binary: it, Path: path, Err: err})
+		}
+	}
+	if len(issues) > 0 {
+		pkg.State = compulsive.StateBroken
+	}
+	return issues
+}
@@ -0,0 +1,110 @@
+package index
+
+import (
+	"bufio"
+	"debug/elf"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var defaultLibraryDirs = []string{"/lib", "/usr/lib", "/lib64", "/usr/lib64", "/usr/local/lib"}
+
+// readLdSoConf gives the library directories listed in a ld.so.conf file,
+// following its include directives.
+func readLdSoConf(path string, seen map[string]bool) []string {
+	if seen[path] {
+		return nil
+	}
+	seen[path] = true
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var dirs []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "include ") {
+			pattern := strings.TrimSpace(line[len("include "):])
+			if !filepath.IsAbs(pattern) {
+				pattern = filepath.Join(filepath.Dir(path), pattern)
+			}
+			matches, _ := filepath.Glob(pattern)
+			for _, it := range matches {
+				dirs = append(dirs, readLdSoConf(it, seen)...)
+			}
+			continue
+		}
+		dirs = append(dirs, line)
+	}
+	return dirs
+}
+
+func expandOrigin(paths []string, origin string) []string {
+	var dirs []string
+	for _, it := range paths {
+		for _, dir := range filepath.SplitList(it) {
+			dir = strings.ReplaceAll(dir, "${ORIGIN}", origin)
+			dirs = append(dirs, strings.ReplaceAll(dir, "$ORIGIN", origin))
+		}
+	}
+	return dirs
+}
+
+// checkLibraries makes sure every shared library an ELF binary depends on
+// can be found by the dynamic linker.
+func checkLibraries(path string) error {
+	f, err := elf.Open(path)
+	if err != nil {
+		// not an ELF binary, nothing to resolve
+		return nil
+	}
+	defer f.Close()
+	needed, err := f.DynString(elf.DT_NEEDED)
+	if err != nil || len(needed) == 0 {
+		return nil
+	}
+	origin := filepath.Dir(path)
+	rpath, _ := f.DynString(elf.DT_RPATH)
+	runpath, _ := f.DynString(elf.DT_RUNPATH)
+	var dirs []string
+	if len(runpath) == 0 {
+		dirs = append(dirs, expandOrigin(rpath, origin)...)
+	}
+	dirs = append(dirs, filepath.SplitList(os.Getenv("LD_LIBRARY_PATH"))...)
+	dirs = append(dirs, expandOrigin(runpath, origin)...)
+	dirs = append(dirs, readLdSoConf("/etc/ld.so.conf", make(map[string]bool))...)
+	dirs = append(dirs, defaultLibraryDirs...)
+	var missing []string
+	for _, lib := range needed {
+		if strings.ContainsRune(lib, '/') {
+			if _, err := os.Stat(lib); err != nil {
+				missing = append(missing, lib)
+			}
+			continue
+		}
+		found := false
+		for _, dir := range dirs {
+			if _, err := os.Stat(filepath.Join(dir, lib)); err == nil {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, lib)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing libraries: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
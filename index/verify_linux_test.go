@@ -0,0 +1,61 @@
+package index
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadLdSoConf(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "ld.so.conf.d"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"ld.so.conf":               "# comment\ninclude ld.so.conf.d/*.conf\n/opt/lib # trailing\n",
+		"ld.so.conf.d/cuda.conf":   "/usr/local/cuda/lib64\n",
+		"ld.so.conf.d/loop.conf":   "include " + filepath.Join(dir, "ld.so.conf") + "\n",
+		"ld.so.conf.d/ignored.txt": "/ignored\n",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected := []string{"/usr/local/cuda/lib64", "/opt/lib"}
+	if got := readLdSoConf(filepath.Join(dir, "ld.so.conf"), make(map[string]bool)); !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestExpandOrigin(t *testing.T) {
+	expected := []string{"/opt/tool/bin/../lib", "/opt/tool/bin/lib", "/usr/lib"}
+	if got := expandOrigin([]string{"$ORIGIN/../lib:${ORIGIN}/lib", "/usr/lib"}, "/opt/tool/bin"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestCheckLibraries(t *testing.T) {
+	raw, err := ioutil.ReadFile("/bin/sh")
+	if err != nil || !bytes.Contains(raw, []byte("libc.so.6\x00")) {
+		t.Skip("no dynamically linked shell to test with")
+	}
+	if err := checkLibraries("/bin/sh"); err != nil {
+		t.Errorf("got %v", err)
+	}
+	// same binary depending on a library that does not exist
+	path := filepath.Join(t.TempDir(), "sh")
+	raw = bytes.Replace(raw, []byte("libc.so.6\x00"), []byte("libq.so.6\x00"), -1)
+	if err := ioutil.WriteFile(path, raw, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkLibraries(path); err == nil || err.Error() != "missing libraries: libq.so.6" {
+		t.Errorf("got %v", err)
+	}
+	if err := checkLibraries(writeExecutable(t, t.TempDir(), "script")); err != nil {
+		t.Errorf("got %v for a script", err)
+	}
+}
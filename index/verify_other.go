@@ -0,0 +1,8 @@
+//go:build !linux
+
+package index
+
+// checkLibraries is only implemented for ELF binaries on Linux.
+func checkLibraries(path string) error {
+	return nil
+}
@@ -0,0 +1,48 @@
+package index
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/casimir/compulsive"
+)
+
+func TestLocateBinary(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "python"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	tool := writeExecutable(t, dir, "tool")
+	link := filepath.Join(dir, "dangling")
+	if err := os.Symlink(filepath.Join(dir, "missing"), link); err != nil {
+		t.Fatal(err)
+	}
+	pkg := compulsive.Package{Provider: &fakeProvider{name: "brew", dirs: []string{dir}}}
+	for name, expected := range map[string]string{"tool": tool, "dangling": link, "python": "", "missing": ""} {
+		if got := LocateBinary(pkg, name); got != expected {
+			t.Errorf("LocateBinary(%q) = %q", name, got)
+		}
+	}
+}
+
+func TestCheckInterpreter(t *testing.T) {
+	dir := t.TempDir()
+	interpreter := writeExecutable(t, dir, "interpreter")
+	cases := map[string]bool{
+		"#!" + interpreter + " -u\n":                      true,
+		"#!" + filepath.Join(dir, "missing") + "\n":       false,
+		"#!/usr/bin/env compulsive-missing-interpreter\n": false,
+		"\x7fELF": true,
+	}
+	for content, ok := range cases {
+		path := filepath.Join(dir, "script")
+		if err := ioutil.WriteFile(path, []byte(content), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := checkInterpreter(path); (err == nil) != ok {
+			t.Errorf("checkInterpreter(%q) = %v", content, err)
+		}
+	}
+}
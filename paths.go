@@ -0,0 +1,21 @@
+package compulsive
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// DataDir gives the directory where compulsive keeps its state, following
+// the XDG base directory specification.
+func DataDir() (string, error) {
+	base := os.Getenv("XDG_DATA_HOME")
+	if base == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		base = filepath.Join(home, ".local", "share")
+	}
+	dir := filepath.Join(base, "compulsive")
+	return dir, os.MkdirAll(dir, 0755)
+}
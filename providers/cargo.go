@@ -3,15 +3,16 @@ package providers
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
-	"net/http"
 	"os/exec"
-	"os/user"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/casimir/compulsive"
 )
@@ -19,6 +20,7 @@ import (
 var (
 	cargoRe      = regexp.MustCompile(`^cargo (?P<version>\d+\.\d+\.\d+)`)
 	cargoEntryRe = regexp.MustCompile(`"(?P<name>\S+) (?P<version>\S+) \((?P<uri>\S+)\)" = \[(?P<binaries>[^]]+)\]`)
+	cargoIDRe    = regexp.MustCompile(`^(?P<name>\S+) (?P<version>\S+) \((?P<uri>\S+)\)$`)
 )
 
 type (
@@ -27,13 +29,31 @@ type (
 		version  string
 		uri      string
 		binaries []string
+		options  cargoInstallOptions
+		root     string
 	}
 
-	cargoPkgPayload struct {
-		Crate struct {
-			Description string
-			MaxVersion  string `json:"max_version"`
-		}
+	// cargoInstallOptions are the options a crate was installed with, as
+	// recorded in .crates2.json.
+	cargoInstallOptions struct {
+		features          []string
+		allFeatures       bool
+		noDefaultFeatures bool
+		profile           string
+		target            string
+		rustc             string
+	}
+
+	cargoInstallPayload struct {
+		Installs map[string]struct {
+			Bins              []string `json:"bins"`
+			Features          []string `json:"features"`
+			AllFeatures       bool     `json:"all_features"`
+			NoDefaultFeatures bool     `json:"no_default_features"`
+			Profile           string   `json:"profile"`
+			Target            string   `json:"target"`
+			Rustc             string   `json:"rustc"`
+		} `json:"installs"`
 	}
 )
 
@@ -70,20 +90,137 @@ func unmarshalManifest(raw []byte) []cargoManifestEntry {
 	return manifest
 }
 
+// unmarshalInstalls reads the .crates2.json manifest, which also records
+// how each crate was built.
+func unmarshalInstalls(raw []byte) ([]cargoManifestEntry, error) {
+	var payload cargoInstallPayload
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return nil, err
+	}
+	var manifest []cargoManifestEntry
+	for id, it := range payload.Installs {
+		matches := cargoIDRe.FindStringSubmatch(id)
+		if matches == nil {
+			continue
+		}
+		manifest = append(manifest, cargoManifestEntry{
+			name:     matches[1],
+			version:  matches[2],
+			uri:      matches[3],
+			binaries: it.Bins,
+			options: cargoInstallOptions{
+				features:          it.Features,
+				allFeatures:       it.AllFeatures,
+				noDefaultFeatures: it.NoDefaultFeatures,
+				profile:           it.Profile,
+				target:            it.Target,
+				rustc:             strings.SplitN(it.Rustc, "\n", 2)[0],
+			},
+		})
+	}
+	sort.Slice(manifest, func(i, j int) bool { return manifest[i].name < manifest[j].name })
+	return manifest, nil
+}
+
+// args gives the cargo install flags reproducing the options, the target
+// being omitted when it is the host one.
+func (o cargoInstallOptions) args(host string) []string {
+	var args []string
+	if len(o.features) > 0 {
+		args = append(args, "--features", strings.Join(o.features, ","))
+	}
+	if o.allFeatures {
+		args = append(args, "--all-features")
+	}
+	if o.noDefaultFeatures {
+		args = append(args, "--no-default-features")
+	}
+	if o.profile != "" && o.profile != "release" {
+		args = append(args, "--profile", o.profile)
+	}
+	if o.target != "" && o.target != host {
+		args = append(args, "--target", o.target)
+	}
+	return args
+}
+
+func (o cargoInstallOptions) attributes() map[string]string {
+	attrs := make(map[string]string)
+	if len(o.features) > 0 {
+		attrs["Features"] = strings.Join(o.features, ", ")
+	}
+	if o.allFeatures {
+		attrs["Features"] = "all"
+	}
+	if o.noDefaultFeatures {
+		attrs["Default features"] = "disabled"
+	}
+	if o.profile != "" {
+		attrs["Profile"] = o.profile
+	}
+	if o.target != "" {
+		attrs["Target"] = o.target
+	}
+	if o.rustc != "" {
+		attrs["Rustc"] = o.rustc
+	}
+	return attrs
+}
+
+// rustHost gives the target triple of the host, as reported by rustc.
+func rustHost() string {
+	out, err := exec.Command("rustc", "-vV").Output()
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if strings.HasPrefix(line, "host: ") {
+			return strings.TrimSpace(strings.TrimPrefix(line, "host: "))
+		}
+	}
+	return ""
+}
+
+// isCratesIO tells whether a source URI designates crates.io, through
+// either of its index protocols.
+func isCratesIO(uri string) bool {
+	return uri == "registry+https://github.com/rust-lang/crates.io-index" || uri == "sparse+https://index.crates.io/"
+}
+
+// fetchPkgInfo resolves the next version of a crate from the registry or
+// git repository it was installed from. With a local registry, registry
+// crates are resolved from it and git ones are left unknown.
 func fetchPkgInfo(uri string, pkg *compulsive.Package) error {
-	if uri != "registry+https://github.com/rust-lang/crates.io-index" {
+	var src indexSource
+	isRegistry := strings.HasPrefix(uri, "registry+") || strings.HasPrefix(uri, "sparse+")
+	switch {
+	case CargoLocalRegistry != "":
+		if !isRegistry {
+			return nil
+		}
+		src = localIndex{dir: CargoLocalRegistry}
+	case isCratesIO(uri):
+		src = cratesIO()
+	case strings.HasPrefix(uri, "git+"):
+		return fetchGitInfo(uri, pkg)
+	case isRegistry:
+		src = registrySource(uri)
+	default:
 		return nil
 	}
-	resp, err := http.Get("https://crates.io/api/v1/crates/" + pkg.Name)
+	entries, err := src.versions(crateName(*pkg))
 	if err != nil {
 		return err
 	}
-	var payload cargoPkgPayload
-	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
-		return err
+	releases, latest := indexReleases(entries, pkg.Version)
+	if latest == "" {
+		return fmt.Errorf("no available version")
+	}
+	pkg.NextVersion = latest
+	if rel, ok := pickRelease(releases, latest, pkg.Version, time.Now()); ok {
+		pkg.NextVersion = rel.version
+		pkg.Released = rel.date
 	}
-	pkg.Summary = payload.Crate.Description
-	pkg.NextVersion = payload.Crate.MaxVersion
 	if pkg.Version == pkg.NextVersion {
 		pkg.State = compulsive.StateUpToDate
 	} else {
@@ -94,12 +231,19 @@ func fetchPkgInfo(uri string, pkg *compulsive.Package) error {
 
 type Cargo struct {
 	manifest []cargoManifestEntry
+	host     string
+	roots    []string
+	binstall *bool
 }
 
 func (p *Cargo) Name() string {
 	return "cargo"
 }
 
+func (p *Cargo) Ecosystem() string {
+	return "crates.io"
+}
+
 func (p *Cargo) IsAvailable() bool {
 	out, err := exec.Command("cargo", "version").Output()
 	if err != nil {
@@ -108,52 +252,221 @@ func (p *Cargo) IsAvailable() bool {
 	return cargoRe.Match(out)
 }
 
+func (p *Cargo) Dependencies() []string {
+	return []string{"rustup"}
+}
+
 func (p *Cargo) Sync() error {
 	return nil
 }
 
+// installRoots gives the install roots, the first one being the default.
+func (p *Cargo) installRoots() []string {
+	if p.roots == nil {
+		roots, err := cargoRoots()
+		if err != nil {
+			log.Printf("could not resolve cargo install roots: %s", err)
+		}
+		p.roots = roots
+	}
+	return p.roots
+}
+
+func (p *Cargo) BinaryDirs() []string {
+	var dirs []string
+	for _, it := range p.installRoots() {
+		dirs = append(dirs, filepath.Join(it, "bin"))
+	}
+	return dirs
+}
+
+func (p *Cargo) NeedsRoot(op compulsive.Operation) bool {
+	if op != compulsive.OpUpdate {
+		return false
+	}
+	for _, it := range p.installRoots() {
+		if fileExists(it) && !isWritable(it) {
+			return true
+		}
+	}
+	return false
+}
+
+func loadRootManifest(root string) ([]cargoManifestEntry, error) {
+	var manifest []cargoManifestEntry
+	if raw, err := ioutil.ReadFile(filepath.Join(root, ".crates2.json")); err == nil {
+		if manifest, err = unmarshalInstalls(raw); err != nil {
+			log.Printf("could not read %s/.crates2.json, falling back to .crates.toml: %s", root, err)
+		}
+	}
+	if manifest == nil {
+		raw, err := ioutil.ReadFile(filepath.Join(root, ".crates.toml"))
+		if err != nil {
+			return nil, err
+		}
+		manifest = unmarshalManifest(raw)
+	}
+	for i := range manifest {
+		manifest[i].root = root
+	}
+	return manifest, nil
+}
+
+// loadManifest reads the crates installed in every root, roots without
+// manifest being skipped.
 func (p *Cargo) loadManifest() error {
-	usr, err := user.Current()
-	if err != nil {
-		return err
+	p.manifest = nil
+	var firstErr error
+	found := false
+	for _, it := range p.installRoots() {
+		manifest, err := loadRootManifest(it)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		found = true
+		p.manifest = append(p.manifest, manifest...)
 	}
-	manifestPath := filepath.Join(usr.HomeDir, ".cargo", ".crates.toml")
-	raw, err := ioutil.ReadFile(manifestPath)
-	if err != nil {
-		return err
+	if !found {
+		if firstErr == nil {
+			firstErr = errors.New("no install root found")
+		}
+		return firstErr
 	}
-	p.manifest = unmarshalManifest(raw)
+	p.host = rustHost()
 	return nil
 }
 
+// rootArgs targets the root a package was found in, unless it is the
+// default one.
+func (p *Cargo) rootArgs(pkg compulsive.Package) []string {
+	root := pkg.Attributes["Root"]
+	if roots := p.installRoots(); root != "" && len(roots) > 0 && root != roots[0] {
+		return []string{"--root", root}
+	}
+	return nil
+}
+
+// installArgs gives the cargo install flags reinstalling a package as it
+// was, in the root it was found in.
+func (p *Cargo) installArgs(pkg compulsive.Package) []string {
+	args := p.rootArgs(pkg)
+	if entry := p.entry(pkg); entry != nil {
+		args = append(args, sourceArgs(entry.uri)...)
+		return append(args, entry.options.args(p.host)...)
+	}
+	return args
+}
+
+// crateName gives the name of the crate of a package, which differs from
+// the package name when the crate is installed in several roots.
+func crateName(pkg compulsive.Package) string {
+	if name := pkg.Attributes["Crate"]; name != "" {
+		return name
+	}
+	return pkg.Name
+}
+
+func (p *Cargo) entry(pkg compulsive.Package) *cargoManifestEntry {
+	root := pkg.Attributes["Root"]
+	for i, it := range p.manifest {
+		if it.name == crateName(pkg) && (root == "" || it.root == root) {
+			return &p.manifest[i]
+		}
+	}
+	return nil
+}
+
+// isVersioned tells whether a package comes from a registry, where
+// versions can be selected.
+func (p *Cargo) isVersioned(pkg compulsive.Package) bool {
+	entry := p.entry(pkg)
+	return entry == nil || strings.HasPrefix(entry.uri, "registry+") || strings.HasPrefix(entry.uri, "sparse+")
+}
+
 func (p *Cargo) List() ([]compulsive.Package, error) {
 	if err := p.loadManifest(); err != nil {
 		return nil, fmt.Errorf("could not build package list: %s", err)
 	}
 	var pkgs []compulsive.Package
+	var uris []string
+	seen := make(map[string]bool)
 	for _, it := range p.manifest {
 		pkg := compulsive.Package{
-			Provider: p,
-			Name:     it.name,
-			Label:    it.name,
-			Binaries: it.binaries,
-			State:    compulsive.StateUnknown,
-			Version:  it.version,
+			Provider:   p,
+			Name:       it.name,
+			Label:      it.name,
+			Binaries:   it.binaries,
+			State:      compulsive.StateUnknown,
+			Version:    it.version,
+			Attributes: it.options.attributes(),
+		}
+		pkg.Attributes["Root"] = it.root
+		pkg.Summary = crateDescription(it.name, it.version)
+		if seen[it.name] {
+			// the crate is also installed in a root listed before
+			pkg.Name = it.name + "@" + it.root
+			pkg.Attributes["Crate"] = it.name
 		}
-		if err := fetchPkgInfo(it.uri, &pkg); err != nil {
-			log.Printf("failed to fetch data for package %q: %s", it.name, err)
+		seen[it.name] = true
+		if !isCratesIO(it.uri) {
+			pkg.Attributes["Source"] = it.uri
+		}
+		if p.useBinstall(pkg) {
+			pkg.Attributes["Update method"] = "cargo binstall, cargo install without prebuilt binary"
+		} else {
+			pkg.Attributes["Update method"] = "cargo install"
 		}
 		pkgs = append(pkgs, pkg)
+		uris = append(uris, it.uri)
 	}
+	fetchPkgInfos(uris, pkgs)
 	return pkgs, nil
 }
 
+// UpdateCommand reinstalls the crates with the options they were installed
+// with, crates sharing the same options being installed together. Crates
+// eligible to cargo binstall are updated one by one.
 func (p *Cargo) UpdateCommand(pkgs ...compulsive.Package) string {
-	var names []string
+	var commands []string
+	byArgs := make(map[string][]string)
+	var keys []string
 	for _, it := range pkgs {
-		names = append(names, it.Name)
+		if p.useBinstall(it) {
+			commands = append(commands, p.binstallCommand(it))
+			continue
+		}
+		if MinReleaseAge > 0 && p.isVersioned(it) {
+			// the latest version may still be cooling down, pin the crate
+			commands = append(commands, p.InstallVersionCommand(it, it.NextVersion))
+			continue
+		}
+		key := strings.Join(p.installArgs(it), " ")
+		if _, ok := byArgs[key]; !ok {
+			keys = append(keys, key)
+		}
+		byArgs[key] = append(byArgs[key], crateName(it))
+	}
+	for _, key := range keys {
+		command := "cargo install --force "
+		if key != "" {
+			command += key + " "
+		}
+		commands = append(commands, command+strings.Join(byArgs[key], " "))
+	}
+	return strings.Join(commands, "\n")
+}
+
+// InstallVersionCommand pins a registry crate to a version, crates built
+// from git or a local path having no versions to pick from.
+func (p *Cargo) InstallVersionCommand(pkg compulsive.Package, version string) string {
+	if !p.isVersioned(pkg) {
+		return ""
 	}
-	return "cargo install --force " + strings.Join(names, " ")
+	args := append([]string{"cargo", "install", "--force"}, p.installArgs(pkg)...)
+	return strings.Join(append(args, "--version", version, crateName(pkg)), " ")
 }
 
 func NewCargo() compulsive.Provider {
@@ -0,0 +1,78 @@
+package providers
+
+import (
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/casimir/compulsive"
+)
+
+// CargoBinstall tells whether crates are updated with cargo binstall when it
+// is installed ("auto") or always built from source ("never").
+var CargoBinstall = "auto"
+
+// hasBinstall tells whether cargo binstall is installed, in the PATH or in
+// one of the install roots.
+func (p *Cargo) hasBinstall() bool {
+	if p.binstall != nil {
+		return *p.binstall
+	}
+	name := "cargo-binstall"
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	_, err := exec.LookPath(name)
+	found := err == nil
+	for _, it := range p.installRoots() {
+		found = found || fileExists(filepath.Join(it, "bin", name))
+	}
+	p.binstall = &found
+	return found
+}
+
+// useBinstall tells whether a package can be updated from a prebuilt
+// binary, which only exists for registry crates built with the default
+// options. Binaries are downloaded from the network, so a local registry
+// always builds from source.
+func (p *Cargo) useBinstall(pkg compulsive.Package) bool {
+	if CargoBinstall != "auto" || CargoLocalRegistry != "" {
+		return false
+	}
+	if !p.isVersioned(pkg) || !p.hasBinstall() {
+		return false
+	}
+	if entry := p.entry(pkg); entry != nil {
+		opts := entry.options
+		if len(opts.features) > 0 || opts.allFeatures || opts.noDefaultFeatures {
+			return false
+		}
+		if opts.profile != "" && opts.profile != "release" {
+			return false
+		}
+	}
+	return true
+}
+
+// binstallCommand installs the prebuilt binary of a package, compiling it
+// with cargo install when none is published.
+func (p *Cargo) binstallCommand(pkg compulsive.Package) string {
+	args := []string{"cargo", "binstall", "--no-confirm", "--force", "--disable-strategies", "compile"}
+	args = append(args, p.rootArgs(pkg)...)
+	var fallback []string
+	if entry := p.entry(pkg); entry != nil {
+		args = append(args, sourceArgs(entry.uri)...)
+		if entry.options.target != "" && entry.options.target != p.host {
+			args = append(args, "--targets", entry.options.target)
+		}
+	}
+	spec := crateName(pkg)
+	if pkg.NextVersion != "" {
+		spec += "@" + pkg.NextVersion
+		fallback = strings.Fields(p.InstallVersionCommand(pkg, pkg.NextVersion))
+	} else {
+		fallback = append(append([]string{"cargo", "install", "--force"}, p.installArgs(pkg)...), crateName(pkg))
+	}
+	return strings.Join(append(args, spec), " ") + " || " + strings.Join(fallback, " ")
+}
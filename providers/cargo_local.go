@@ -0,0 +1,83 @@
+package providers
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// CargoLocalRegistry is a local registry directory versions are resolved
+// from instead of the network, for machines without access to it.
+var CargoLocalRegistry string
+
+// unmarshalPackageField gives a string field of the [package] table of a
+// Cargo.toml.
+func unmarshalPackageField(raw []byte, key string) string {
+	section := ""
+	for _, line := range bytes.Split(raw, []byte("\n")) {
+		text := strings.TrimSpace(string(line))
+		if matches := tomlSectionRe.FindStringSubmatch(text); matches != nil {
+			section = matches[1]
+			continue
+		}
+		matches := tomlKeyValueRe.FindStringSubmatch(text)
+		if section != "package" || matches == nil || matches[1] != key {
+			continue
+		}
+		if str := tomlStringRe.FindStringSubmatch(matches[2]); str != nil {
+			return str[1]
+		}
+	}
+	return ""
+}
+
+// localIndex reads versions from a local registry directory, which can be
+// a cargo local-registry (index in an index directory), a cloned registry
+// index or a cargo vendor directory.
+type localIndex struct {
+	dir string
+}
+
+func (l localIndex) versions(name string) ([]cratesIndexEntry, error) {
+	for _, it := range []string{filepath.Join(l.dir, "index"), l.dir} {
+		raw, err := ioutil.ReadFile(filepath.Join(it, filepath.FromSlash(cratesIndexPath(name))))
+		if err == nil {
+			return unmarshalIndexEntries(raw)
+		}
+	}
+	entries, err := l.vendored(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("crate not found in %s", l.dir)
+	}
+	return entries, nil
+}
+
+// vendored gives the versions of a crate in a cargo vendor layout, where
+// crates live in name or name-version directories. Both are described by
+// their Cargo.toml, as a directory name does not tell apart a version from
+// the rest of another crate name.
+func (l localIndex) vendored(name string) ([]cratesIndexEntry, error) {
+	dirs, err := ioutil.ReadDir(l.dir)
+	if err != nil {
+		return nil, err
+	}
+	var entries []cratesIndexEntry
+	for _, it := range dirs {
+		if !it.IsDir() || (it.Name() != name && !strings.HasPrefix(it.Name(), name+"-")) {
+			continue
+		}
+		raw, err := ioutil.ReadFile(filepath.Join(l.dir, it.Name(), "Cargo.toml"))
+		if err != nil || unmarshalPackageField(raw, "name") != name {
+			continue
+		}
+		if version := unmarshalPackageField(raw, "version"); version != "" {
+			entries = append(entries, cratesIndexEntry{Name: name, Vers: version})
+		}
+	}
+	return entries, nil
+}
@@ -0,0 +1,46 @@
+package providers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLocalIndex(t *testing.T) {
+	dir, err := ioutil.TempDir("", "registry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	registry := filepath.Join(dir, "registry")
+	writeFile(t, filepath.Join(registry, "index", "ri", "pg", "ripgrep"), `{"name":"ripgrep","vers":"14.1.0","yanked":false}`+"\n")
+	if entries, err := (localIndex{dir: registry}).versions("ripgrep"); err != nil || len(entries) != 1 || entries[0].Vers != "14.1.0" {
+		t.Errorf("local registry: %v, %v", entries, err)
+	}
+
+	vendor := filepath.Join(dir, "vendor")
+	writeFile(t, filepath.Join(vendor, "serde", "Cargo.toml"), "[package]\nname = \"serde\"\nversion = \"1.0.200\"\n")
+	writeFile(t, filepath.Join(vendor, "serde-1.0.150", "Cargo.toml"), "[package]\nname = \"serde\"\nversion = \"1.0.150\"\n")
+	writeFile(t, filepath.Join(vendor, "serde_json", "Cargo.toml"), "[package]\nversion = \"1.0.0\"\n")
+	writeFile(t, filepath.Join(vendor, "serde-1-compat", "Cargo.toml"), "[package]\nname = \"serde-1-compat\"\nversion = \"0.1.0\"\n")
+	entries, err := (localIndex{dir: vendor}).versions("serde")
+	expected := []cratesIndexEntry{{Name: "serde", Vers: "1.0.200"}, {Name: "serde", Vers: "1.0.150"}}
+	if err != nil || !reflect.DeepEqual(entries, expected) {
+		t.Errorf("vendor: %v, %v", entries, err)
+	}
+	if _, err := (localIndex{dir: vendor}).versions("tokio"); err == nil {
+		t.Errorf("expected an error for a missing crate")
+	}
+}
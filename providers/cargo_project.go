@@ -0,0 +1,279 @@
+package providers
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/casimir/compulsive"
+)
+
+var (
+	tomlSectionRe  = regexp.MustCompile(`^\[\s*([^\]]+?)\s*\]`)
+	tomlKeyValueRe = regexp.MustCompile(`^([A-Za-z0-9_.-]+)\s*=\s*(.*)$`)
+	tomlStringRe   = regexp.MustCompile(`"([^"]*)"`)
+	tomlInlineRe   = regexp.MustCompile(`\b([A-Za-z_-]+)\s*=\s*("[^"]*"|true|false)`)
+)
+
+type cargoDependency struct {
+	name    string
+	kind    string
+	version string
+	local   bool
+	// rename is the key of a dependency renamed with the package field.
+	rename string
+	// target is the platform of a target specific dependency.
+	target string
+}
+
+// cargoDependencyKind gives the kind, the target and the name of the
+// dependency declared by a table, if it is a dependency table.
+func cargoDependencyKind(section string) (string, string, string, bool) {
+	parts := strings.Split(section, ".")
+	for i, it := range parts {
+		var kind string
+		switch it {
+		case "dependencies":
+			kind = ""
+		case "dev-dependencies":
+			kind = "dev"
+		case "build-dependencies":
+			kind = "build"
+		default:
+			continue
+		}
+		var target string
+		if i >= 2 && parts[0] == "target" {
+			target = strings.Trim(strings.Join(parts[1:i], "."), `"'`)
+		}
+		if i+1 < len(parts) {
+			return kind, target, strings.Trim(strings.Join(parts[i+1:], "."), `"`), true
+		}
+		return kind, target, "", true
+	}
+	return "", "", "", false
+}
+
+func applyCargoDependencyFields(dep *cargoDependency, fields map[string]string) {
+	if pkg, ok := fields["package"]; ok && pkg != dep.name {
+		dep.rename, dep.name = dep.name, pkg
+	}
+	if version, ok := fields["version"]; ok {
+		dep.version = version
+	}
+	_, hasPath := fields["path"]
+	_, hasGit := fields["git"]
+	_, hasRegistry := fields["registry"]
+	dep.local = hasPath || hasGit || hasRegistry || fields["workspace"] == "true"
+}
+
+func parseInlineTable(raw string) map[string]string {
+	fields := make(map[string]string)
+	for _, it := range tomlInlineRe.FindAllStringSubmatch(raw, -1) {
+		fields[it[1]] = strings.Trim(it[2], `"`)
+	}
+	return fields
+}
+
+// unmarshalCargoDependencies extracts the direct dependencies declared in a
+// Cargo.toml, whatever the table they are declared in.
+func unmarshalCargoDependencies(raw []byte) []cargoDependency {
+	var deps []cargoDependency
+	var table *cargoDependency
+	tableFields := make(map[string]string)
+	inDeps, kind, target := false, "", ""
+	flushTable := func() {
+		if table != nil {
+			applyCargoDependencyFields(table, tableFields)
+			deps = append(deps, *table)
+			table, tableFields = nil, make(map[string]string)
+		}
+	}
+	for _, line := range bytes.Split(raw, []byte("\n")) {
+		text := strings.TrimSpace(string(line))
+		if matches := tomlSectionRe.FindStringSubmatch(text); matches != nil {
+			flushTable()
+			var name string
+			kind, target, name, inDeps = cargoDependencyKind(matches[1])
+			if inDeps && name != "" {
+				table = &cargoDependency{name: name, kind: kind, target: target}
+				inDeps = false
+			}
+			continue
+		}
+		matches := tomlKeyValueRe.FindStringSubmatch(text)
+		if matches == nil {
+			continue
+		}
+		if table != nil {
+			tableFields[matches[1]] = strings.Trim(strings.TrimSpace(matches[2]), `"`)
+			continue
+		}
+		if !inDeps {
+			continue
+		}
+		dep := cargoDependency{name: matches[1], kind: kind, target: target}
+		value := strings.TrimSpace(matches[2])
+		if strings.HasPrefix(value, "{") {
+			applyCargoDependencyFields(&dep, parseInlineTable(value))
+		} else if str := tomlStringRe.FindStringSubmatch(value); str != nil {
+			dep.version = str[1]
+		}
+		deps = append(deps, dep)
+	}
+	flushTable()
+	return deps
+}
+
+type cargoLockEntry struct {
+	name    string
+	version string
+	source  string
+}
+
+func unmarshalCargoLock(raw []byte) map[string]cargoLockEntry {
+	entries := make(map[string]cargoLockEntry)
+	var current *cargoLockEntry
+	flush := func() {
+		if current == nil || current.name == "" {
+			return
+		}
+		if prev, ok := entries[current.name]; !ok || compulsive.CompareVersions(prev.version, current.version) < 0 {
+			entries[current.name] = *current
+		}
+	}
+	for _, line := range bytes.Split(raw, []byte("\n")) {
+		text := strings.TrimSpace(string(line))
+		if text == "[[package]]" {
+			flush()
+			current = &cargoLockEntry{}
+			continue
+		}
+		if strings.HasPrefix(text, "[") {
+			flush()
+			current = nil
+			continue
+		}
+		matches := tomlKeyValueRe.FindStringSubmatch(text)
+		if current == nil || matches == nil {
+			continue
+		}
+		value := strings.Trim(matches[2], `"`)
+		switch matches[1] {
+		case "name":
+			current.name = value
+		case "version":
+			current.version = value
+		case "source":
+			current.source = value
+		}
+	}
+	flush()
+	return entries
+}
+
+type CargoProject struct {
+	root string
+	deps map[string]cargoDependency
+}
+
+func (p *CargoProject) Name() string {
+	return "cargo"
+}
+
+func (p *CargoProject) manifestPath() string {
+	return filepath.Join(p.root, "Cargo.toml")
+}
+
+func (p *CargoProject) Ecosystem() string {
+	return "crates.io"
+}
+
+func (p *CargoProject) IsAvailable() bool {
+	return fileExists(p.manifestPath()) && (&Cargo{}).IsAvailable()
+}
+
+func (p *CargoProject) Sync() error {
+	return nil
+}
+
+func (p *CargoProject) List() ([]compulsive.Package, error) {
+	raw, err := ioutil.ReadFile(p.manifestPath())
+	if err != nil {
+		return nil, fmt.Errorf("could not read manifest: %s", err)
+	}
+	lock := make(map[string]cargoLockEntry)
+	if rawLock, err := ioutil.ReadFile(filepath.Join(p.root, "Cargo.lock")); err == nil {
+		lock = unmarshalCargoLock(rawLock)
+	}
+	p.deps = make(map[string]cargoDependency)
+	var pkgs []compulsive.Package
+	var uris []string
+	for _, it := range unmarshalCargoDependencies(raw) {
+		if it.local {
+			continue
+		}
+		if _, ok := p.deps[it.name]; ok {
+			continue
+		}
+		p.deps[it.name] = it
+		pkg := compulsive.Package{
+			Provider: p,
+			Name:     it.name,
+			Label:    it.name,
+			State:    compulsive.StateUnknown,
+			Version:  it.version,
+		}
+		uri := "registry+https://github.com/rust-lang/crates.io-index"
+		if locked, ok := lock[it.name]; ok {
+			pkg.Version = locked.version
+			uri = locked.source
+		}
+		pkgs = append(pkgs, pkg)
+		uris = append(uris, uri)
+	}
+	fetchPkgInfos(uris, pkgs)
+	return pkgs, nil
+}
+
+// UpdateCommand updates the dependencies with cargo add, one command per
+// table. Renamed dependencies keep their key with a command of their own.
+func (p *CargoProject) UpdateCommand(pkgs ...compulsive.Package) string {
+	type table struct{ kind, target, rename string }
+	byTable := make(map[table][]string)
+	var tables []table
+	for _, it := range pkgs {
+		dep := p.deps[it.Name]
+		key := table{dep.kind, dep.target, dep.rename}
+		if _, ok := byTable[key]; !ok {
+			tables = append(tables, key)
+		}
+		spec := it.Name
+		if it.NextVersion != "" {
+			spec += "@" + it.NextVersion
+		}
+		byTable[key] = append(byTable[key], spec)
+	}
+	var commands []string
+	for _, it := range tables {
+		command := "cargo add --manifest-path " + shellQuote(p.manifestPath())
+		if it.kind != "" {
+			command += " --" + it.kind
+		}
+		if it.target != "" {
+			command += " --target " + shellQuote(it.target)
+		}
+		if it.rename != "" {
+			command += " --rename " + it.rename
+		}
+		commands = append(commands, command+" "+strings.Join(byTable[it], " "))
+	}
+	return strings.Join(commands, "\n")
+}
+
+func NewCargoProject(root string) compulsive.Provider {
+	return &CargoProject{root: root}
+}
@@ -0,0 +1,89 @@
+package providers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/casimir/compulsive"
+)
+
+func TestUnmarshalCargoDependencies(t *testing.T) {
+	manifestContent := []byte(`[package]
+name = "demo"
+version = "0.1.0"
+
+[dependencies]
+regex = "1.5"
+serde = { version = "1.0", features = ["derive"] }
+local = { path = "../local" }
+rand_core = { package = "rand", version = "0.8" }
+
+[dev-dependencies]
+tempfile = "3"
+
+[dependencies.log]
+version = "0.4"
+
+[target.'cfg(unix)'.dependencies]
+libc = "0.2"
+`)
+	expected := []cargoDependency{
+		{name: "regex", version: "1.5"},
+		{name: "serde", version: "1.0"},
+		{name: "local", local: true},
+		{name: "rand", version: "0.8", rename: "rand_core"},
+		{name: "tempfile", kind: "dev", version: "3"},
+		{name: "log", version: "0.4"},
+		{name: "libc", version: "0.2", target: "cfg(unix)"},
+	}
+	got := unmarshalCargoDependencies(manifestContent)
+	if !reflect.DeepEqual(expected, got) {
+		t.Fail()
+	}
+}
+
+func TestCargoProjectUpdateCommand(t *testing.T) {
+	p := &CargoProject{
+		root: "/src/my project",
+		deps: map[string]cargoDependency{
+			"regex": {name: "regex"},
+			"serde": {name: "serde"},
+			"rand":  {name: "rand", rename: "rand_core"},
+			"libc":  {name: "libc", target: "cfg(unix)"},
+		},
+	}
+	var pkgs []compulsive.Package
+	for _, name := range []string{"regex", "rand", "libc", "serde"} {
+		pkgs = append(pkgs, compulsive.Package{Provider: p, Name: name, NextVersion: "1.0.0"})
+	}
+	expected := "cargo add --manifest-path '/src/my project/Cargo.toml' regex@1.0.0 serde@1.0.0\n" +
+		"cargo add --manifest-path '/src/my project/Cargo.toml' --rename rand_core rand@1.0.0\n" +
+		"cargo add --manifest-path '/src/my project/Cargo.toml' --target 'cfg(unix)' libc@1.0.0"
+	if got := p.UpdateCommand(pkgs...); got != expected {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestUnmarshalCargoLock(t *testing.T) {
+	lockContent := []byte(`# This file is automatically @generated by Cargo.
+version = 3
+
+[[package]]
+name = "regex"
+version = "1.5.4"
+source = "registry+https://github.com/rust-lang/crates.io-index"
+
+[[package]]
+name = "regex"
+version = "1.10.2"
+source = "registry+https://github.com/rust-lang/crates.io-index"
+
+[[package]]
+name = "demo"
+version = "0.1.0"
+`)
+	got := unmarshalCargoLock(lockContent)
+	if got["regex"].version != "1.10.2" || got["demo"].source != "" {
+		t.Fail()
+	}
+}
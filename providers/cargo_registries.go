@@ -0,0 +1,330 @@
+package providers
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/casimir/compulsive"
+)
+
+// indexSource gives the versions of a crate from a registry index.
+type indexSource interface {
+	versions(name string) ([]cratesIndexEntry, error)
+}
+
+// cargoRegistry is an alternative registry declared in the cargo config.
+type cargoRegistry struct {
+	name  string
+	index string
+	token string
+}
+
+// canonicalIndex gives an index URL as it appears in the source of an
+// installed crate, git indexes being prefixed with registry+.
+func canonicalIndex(index string) string {
+	index = strings.TrimSuffix(index, "/")
+	if strings.HasPrefix(index, "sparse+") || strings.HasPrefix(index, "registry+") {
+		return index
+	}
+	return "registry+" + index
+}
+
+// unmarshalCargoRegistries reads the registries declared in a cargo config
+// or credentials file, either as tables or inline tables.
+func unmarshalCargoRegistries(raw []byte, registries map[string]*cargoRegistry) {
+	get := func(name string) *cargoRegistry {
+		if _, ok := registries[name]; !ok {
+			registries[name] = &cargoRegistry{name: name}
+		}
+		return registries[name]
+	}
+	set := func(reg *cargoRegistry, key, value string) {
+		switch key {
+		case "index":
+			reg.index = value
+		case "token":
+			reg.token = value
+		}
+	}
+	section := ""
+	for _, line := range bytes.Split(raw, []byte("\n")) {
+		text := strings.TrimSpace(string(line))
+		if matches := tomlSectionRe.FindStringSubmatch(text); matches != nil {
+			section = matches[1]
+			continue
+		}
+		matches := tomlKeyValueRe.FindStringSubmatch(text)
+		if matches == nil {
+			continue
+		}
+		value := strings.TrimSpace(matches[2])
+		switch {
+		case strings.HasPrefix(section, "registries."):
+			if str := tomlStringRe.FindStringSubmatch(value); str != nil {
+				set(get(strings.Trim(strings.TrimPrefix(section, "registries."), `"`)), matches[1], str[1])
+			}
+		case section == "registries" && strings.HasPrefix(value, "{"):
+			reg := get(matches[1])
+			for key, it := range parseInlineTable(value) {
+				set(reg, key, it)
+			}
+		}
+	}
+}
+
+// registryEnvName gives the name of a registry as used in the
+// CARGO_REGISTRIES_<name>_* environment variables.
+func registryEnvName(name string) string {
+	return strings.ToUpper(strings.Replace(name, "-", "_", -1))
+}
+
+// loadCargoRegistries gives the alternative registries of the cargo config
+// of home, with their tokens from the credentials file or the environment.
+func loadCargoRegistries(home string) []*cargoRegistry {
+	registries := make(map[string]*cargoRegistry)
+	for _, it := range []string{"config", "config.toml", "credentials", "credentials.toml"} {
+		if raw, err := ioutil.ReadFile(filepath.Join(home, it)); err == nil {
+			unmarshalCargoRegistries(raw, registries)
+		}
+	}
+	var list []*cargoRegistry
+	for name, it := range registries {
+		prefix := "CARGO_REGISTRIES_" + registryEnvName(name)
+		if index := os.Getenv(prefix + "_INDEX"); index != "" {
+			it.index = index
+		}
+		if token := os.Getenv(prefix + "_TOKEN"); token != "" {
+			it.token = token
+		}
+		if it.index != "" {
+			list = append(list, it)
+		}
+	}
+	return list
+}
+
+var (
+	registriesOnce  sync.Once
+	registries      []*cargoRegistry
+	registrySources = make(map[string]indexSource)
+	registryMu      sync.Mutex
+)
+
+// findRegistry gives the configured registry a crate source belongs to, or
+// nil if it is not declared.
+func findRegistry(uri string) *cargoRegistry {
+	registriesOnce.Do(func() {
+		if home, err := cargoHome(); err == nil {
+			registries = loadCargoRegistries(home)
+		}
+	})
+	for _, it := range registries {
+		if canonicalIndex(it.index) == strings.TrimSuffix(uri, "/") {
+			return it
+		}
+	}
+	return nil
+}
+
+// registrySource gives the index of the registry a crate comes from, sparse
+// indexes being fetched over HTTP and git ones through a local clone.
+func registrySource(uri string) indexSource {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if src, ok := registrySources[uri]; ok {
+		return src
+	}
+	cacheDir, err := os.UserCacheDir()
+	if err == nil {
+		cacheDir = filepath.Join(cacheDir, "compulsive", "index", url.PathEscape(uri))
+	}
+	var src indexSource
+	if strings.HasPrefix(uri, "sparse+") {
+		client := newCratesClient(uri, cacheDir)
+		if reg := findRegistry(uri); reg != nil {
+			client.token = reg.token
+		}
+		src = client
+	} else {
+		src = &gitIndex{url: strings.TrimPrefix(uri, "registry+"), dir: cacheDir}
+	}
+	registrySources[uri] = src
+	return src
+}
+
+// gitIndex is a registry index served as a git repository, read from a
+// shallow clone refreshed once per run.
+type gitIndex struct {
+	url  string
+	dir  string
+	once sync.Once
+	err  error
+}
+
+func (g *gitIndex) sync() error {
+	g.once.Do(func() {
+		if g.dir == "" {
+			g.err = fmt.Errorf("no cache directory for index %s", g.url)
+			return
+		}
+		if err := exec.Command("git", "init", "--quiet", "--bare", g.dir).Run(); err != nil {
+			g.err = fmt.Errorf("could not create index clone: %s", err)
+			return
+		}
+		if out, err := exec.Command("git", "-C", g.dir, "fetch", "--quiet", "--depth", "1", g.url, "HEAD").CombinedOutput(); err != nil {
+			g.err = fmt.Errorf("could not fetch index %s: %s", g.url, strings.TrimSpace(string(out)))
+		}
+	})
+	return g.err
+}
+
+func (g *gitIndex) versions(name string) ([]cratesIndexEntry, error) {
+	if err := g.sync(); err != nil {
+		return nil, err
+	}
+	out, err := exec.Command("git", "-C", g.dir, "show", "FETCH_HEAD:"+cratesIndexPath(name)).Output()
+	if err != nil {
+		return nil, fmt.Errorf("crate not found in index")
+	}
+	return unmarshalIndexEntries(out)
+}
+
+// gitSource is the source of a crate installed from a git repository, e.g.
+// git+https://example.com/tool?branch=main#0123abcd.
+type gitSource struct {
+	url    string
+	kind   string
+	ref    string
+	commit string
+}
+
+func parseGitSource(uri string) (gitSource, error) {
+	var src gitSource
+	raw := strings.TrimPrefix(uri, "git+")
+	if i := strings.LastIndexByte(raw, '#'); i >= 0 {
+		raw, src.commit = raw[:i], raw[i+1:]
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return src, err
+	}
+	for _, kind := range []string{"branch", "tag", "rev"} {
+		if ref := u.Query().Get(kind); ref != "" {
+			src.kind, src.ref = kind, ref
+		}
+	}
+	u.RawQuery = ""
+	src.url = u.String()
+	return src, nil
+}
+
+func (s gitSource) args() []string {
+	args := []string{"--git", s.url}
+	if s.kind != "" {
+		args = append(args, "--"+s.kind, s.ref)
+	}
+	return args
+}
+
+// remoteCommit gives the commit the reference of the source points to on
+// the remote, tags being peeled.
+func (s gitSource) remoteCommit() (string, error) {
+	var refs []string
+	switch s.kind {
+	case "branch":
+		refs = []string{"refs/heads/" + s.ref}
+	case "tag":
+		refs = []string{"refs/tags/" + s.ref, "refs/tags/" + s.ref + "^{}"}
+	default:
+		refs = []string{"HEAD"}
+	}
+	out, err := exec.Command("git", append([]string{"ls-remote", s.url}, refs...)...).Output()
+	if err != nil {
+		return "", fmt.Errorf("could not query %s: %s", s.url, err)
+	}
+	commit := ""
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if commit == "" || strings.HasSuffix(fields[1], "^{}") {
+			commit = fields[0]
+		}
+	}
+	if commit == "" {
+		return "", fmt.Errorf("reference not found on %s", s.url)
+	}
+	return commit, nil
+}
+
+func shortCommit(commit string) string {
+	if len(commit) > 10 {
+		return commit[:10]
+	}
+	return commit
+}
+
+// fetchGitInfo compares the installed commit of a crate with the one its
+// reference points to, crates pinned to a revision being up to date. The
+// version of an outdated crate is kept as its next version, the new commit
+// being no version to compare.
+func fetchGitInfo(uri string, pkg *compulsive.Package) error {
+	src, err := parseGitSource(uri)
+	if err != nil {
+		return err
+	}
+	if pkg.Attributes == nil {
+		pkg.Attributes = make(map[string]string)
+	}
+	pkg.Attributes["Commit"] = shortCommit(src.commit)
+	if src.kind == "rev" {
+		pkg.State = compulsive.StateUpToDate
+		return nil
+	}
+	remote, err := src.remoteCommit()
+	if err != nil {
+		return err
+	}
+	if remote == src.commit {
+		pkg.NextVersion = pkg.Version
+		pkg.State = compulsive.StateUpToDate
+		return nil
+	}
+	pkg.NextVersion = pkg.Version
+	pkg.Attributes["Remote commit"] = shortCommit(remote)
+	pkg.State = compulsive.StateOutdated
+	return nil
+}
+
+// sourceArgs gives the cargo install flags selecting the source a crate
+// was installed from.
+func sourceArgs(uri string) []string {
+	switch {
+	case isCratesIO(uri):
+		return nil
+	case strings.HasPrefix(uri, "git+"):
+		src, err := parseGitSource(uri)
+		if err != nil {
+			log.Printf("invalid git source %q: %s", uri, err)
+			return nil
+		}
+		return src.args()
+	case strings.HasPrefix(uri, "path+file://"):
+		return []string{"--path", filepath.FromSlash(strings.TrimPrefix(uri, "path+file://"))}
+	case strings.HasPrefix(uri, "registry+"), strings.HasPrefix(uri, "sparse+"):
+		if reg := findRegistry(uri); reg != nil {
+			return []string{"--registry", reg.name}
+		}
+		return []string{"--index", strings.TrimPrefix(uri, "registry+")}
+	}
+	return nil
+}
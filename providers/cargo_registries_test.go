@@ -0,0 +1,141 @@
+package providers
+
+import (
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/casimir/compulsive"
+)
+
+func TestUnmarshalCargoRegistries(t *testing.T) {
+	registries := make(map[string]*cargoRegistry)
+	unmarshalCargoRegistries([]byte(`[registries]
+internal = { index = "sparse+https://crates.corp.example.com/index/" }
+
+[registries.legacy]
+index = "https://git.corp.example.com/crates-index"
+`), registries)
+	unmarshalCargoRegistries([]byte(`[registries.internal]
+token = "Bearer secret"
+`), registries)
+	internal, legacy := registries["internal"], registries["legacy"]
+	if internal == nil || internal.index != "sparse+https://crates.corp.example.com/index/" || internal.token != "Bearer secret" {
+		t.Errorf("unexpected internal registry: %+v", internal)
+	}
+	if legacy == nil || canonicalIndex(legacy.index) != "registry+https://git.corp.example.com/crates-index" {
+		t.Errorf("unexpected legacy registry: %+v", legacy)
+	}
+}
+
+func TestParseGitSource(t *testing.T) {
+	src, err := parseGitSource("git+https://github.com/acme/tool?branch=main#0123456789abcdef")
+	expected := gitSource{url: "https://github.com/acme/tool", kind: "branch", ref: "main", commit: "0123456789abcdef"}
+	if err != nil || src != expected {
+		t.Errorf("got %+v, %v", src, err)
+	}
+	if got := src.args(); !reflect.DeepEqual(got, []string{"--git", "https://github.com/acme/tool", "--branch", "main"}) {
+		t.Errorf("got %v", got)
+	}
+	if src, _ := parseGitSource("git+https://github.com/acme/tool#0123"); src.kind != "" || src.commit != "0123" {
+		t.Errorf("got %+v", src)
+	}
+}
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %s", args, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestFetchGitInfo(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	runGit(t, dir, "init", "--quiet", "--initial-branch", "main")
+	runGit(t, dir, "commit", "--quiet", "--allow-empty", "-m", "first")
+	installed := runGit(t, dir, "rev-parse", "HEAD")
+	runGit(t, dir, "tag", "-a", "-m", "release", "v1.0.0")
+	runGit(t, dir, "commit", "--quiet", "--allow-empty", "-m", "second")
+	head := runGit(t, dir, "rev-parse", "HEAD")
+
+	src := gitSource{url: "file://" + filepath.ToSlash(dir), kind: "tag", ref: "v1.0.0"}
+	if commit, err := src.remoteCommit(); err != nil || commit != installed {
+		t.Errorf("tag resolved to %q, %v", commit, err)
+	}
+
+	pkg := compulsive.Package{Name: "tool", Version: "1.0.0"}
+	uri := "git+file://" + filepath.ToSlash(dir) + "?branch=main#" + installed
+	if err := fetchGitInfo(uri, &pkg); err != nil {
+		t.Fatal(err)
+	}
+	if pkg.State != compulsive.StateOutdated || pkg.NextVersion != "1.0.0" || pkg.Attributes["Remote commit"] != shortCommit(head) {
+		t.Errorf("got %+v", pkg)
+	}
+	if change := compulsive.ClassifyChange(pkg.Version, pkg.NextVersion); change != compulsive.ChangeUnknown {
+		t.Errorf("got a %s change", change)
+	}
+
+	pkg = compulsive.Package{Name: "tool", Version: "1.0.0"}
+	if err := fetchGitInfo("git+file://"+filepath.ToSlash(dir)+"#"+head, &pkg); err != nil || pkg.State != compulsive.StateUpToDate {
+		t.Errorf("got %+v, %v", pkg, err)
+	}
+}
+
+// useRegistries replaces the registries of the cargo config for a test.
+func useRegistries(t *testing.T, list ...*cargoRegistry) {
+	registriesOnce.Do(func() {})
+	saved := registries
+	registries = list
+	t.Cleanup(func() { registries = saved })
+}
+
+func TestSourceArgs(t *testing.T) {
+	useRegistries(t, &cargoRegistry{name: "internal", index: "sparse+https://crates.corp.example.com/index/"})
+	cases := map[string][]string{
+		"registry+https://github.com/rust-lang/crates.io-index":  nil,
+		"sparse+https://crates.corp.example.com/index/":          {"--registry", "internal"},
+		"registry+https://git.other.example.com/index":           {"--index", "https://git.other.example.com/index"},
+		"git+https://github.com/acme/tool?tag=v1.0.0#0123456789": {"--git", "https://github.com/acme/tool", "--tag", "v1.0.0"},
+		"path+file:///src/tool":                                  {"--path", filepath.FromSlash("/src/tool")},
+	}
+	for uri, expected := range cases {
+		if got := sourceArgs(uri); !reflect.DeepEqual(got, expected) {
+			t.Errorf("sourceArgs(%q) = %v", uri, got)
+		}
+	}
+}
+
+func TestUpdateCommandSources(t *testing.T) {
+	useRegistries(t, &cargoRegistry{name: "internal", index: "sparse+https://crates.corp.example.com/index/"})
+	found := false
+	p := &Cargo{
+		manifest: []cargoManifestEntry{
+			{name: "tool", version: "1.0.0", uri: "git+https://github.com/acme/tool?branch=main#0123456789", root: "/cargo"},
+			{name: "lint", version: "0.3.0", uri: "sparse+https://crates.corp.example.com/index/", root: "/cargo"},
+		},
+		roots:    []string{"/cargo"},
+		binstall: &found,
+	}
+	tool := compulsive.Package{Provider: p, Name: "tool", NextVersion: "1.0.0", Attributes: map[string]string{"Root": "/cargo"}}
+	lint := compulsive.Package{Provider: p, Name: "lint", NextVersion: "0.4.0", Attributes: map[string]string{"Root": "/cargo"}}
+	expected := "cargo install --force --git https://github.com/acme/tool --branch main tool\n" +
+		"cargo install --force --registry internal lint"
+	if got := p.UpdateCommand(tool, lint); got != expected {
+		t.Errorf("got %q", got)
+	}
+	defer func(age time.Duration) { MinReleaseAge = age }(MinReleaseAge)
+	MinReleaseAge = 24 * time.Hour
+	expected = "cargo install --force --registry internal --version 0.4.0 lint\n" +
+		"cargo install --force --git https://github.com/acme/tool --branch main tool"
+	if got := p.UpdateCommand(tool, lint); got != expected {
+		t.Errorf("got %q", got)
+	}
+}
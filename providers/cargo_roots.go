@@ -0,0 +1,110 @@
+package providers
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CargoRoots are install roots scanned in addition to the ones cargo is
+// configured with.
+var CargoRoots []string
+
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[1:])
+}
+
+func cargoHome() (string, error) {
+	if home := os.Getenv("CARGO_HOME"); home != "" {
+		return filepath.Abs(expandHome(home))
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".cargo"), nil
+}
+
+// unmarshalInstallRoot gives the install.root setting of a cargo config
+// file, or an empty string if it is not set.
+func unmarshalInstallRoot(raw []byte) string {
+	section := ""
+	for _, line := range bytes.Split(raw, []byte("\n")) {
+		text := strings.TrimSpace(string(line))
+		if matches := tomlSectionRe.FindStringSubmatch(text); matches != nil {
+			section = matches[1]
+			continue
+		}
+		matches := tomlKeyValueRe.FindStringSubmatch(text)
+		if matches == nil {
+			continue
+		}
+		key := matches[1]
+		if section != "" {
+			key = section + "." + key
+		}
+		if key == "install.root" {
+			if str := tomlStringRe.FindStringSubmatch(matches[2]); str != nil {
+				return str[1]
+			}
+		}
+	}
+	return ""
+}
+
+// cargoConfigRoot reads install.root from the cargo config of home, relative
+// paths being resolved from the parent of home as cargo does.
+func cargoConfigRoot(home string) string {
+	for _, it := range []string{"config.toml", "config"} {
+		raw, err := ioutil.ReadFile(filepath.Join(home, it))
+		if err != nil {
+			continue
+		}
+		root := unmarshalInstallRoot(raw)
+		if root == "" {
+			return ""
+		}
+		if root = expandHome(root); !filepath.IsAbs(root) {
+			root = filepath.Join(filepath.Dir(home), root)
+		}
+		return root
+	}
+	return ""
+}
+
+// cargoRoots gives the root cargo install uses by default, followed by the
+// other roots holding installed crates. The default root comes from
+// CARGO_INSTALL_ROOT, install.root or CARGO_HOME, in that order.
+func cargoRoots() ([]string, error) {
+	home, err := cargoHome()
+	if err != nil {
+		return nil, err
+	}
+	def := os.Getenv("CARGO_INSTALL_ROOT")
+	if def == "" {
+		def = cargoConfigRoot(home)
+	}
+	if def == "" {
+		def = home
+	}
+	var roots []string
+	seen := make(map[string]bool)
+	for _, it := range append([]string{def, home}, CargoRoots...) {
+		root, err := filepath.Abs(expandHome(it))
+		if err != nil || seen[root] {
+			continue
+		}
+		seen[root] = true
+		roots = append(roots, root)
+	}
+	return roots, nil
+}
@@ -0,0 +1,72 @@
+package providers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestUnmarshalInstallRoot(t *testing.T) {
+	raw := []byte(`[build]
+jobs = 4
+
+[install]
+root = "/opt/cargo"
+`)
+	if got := unmarshalInstallRoot(raw); got != "/opt/cargo" {
+		t.Errorf("got %q", got)
+	}
+	if got := unmarshalInstallRoot([]byte(`install.root = "tools"`)); got != "tools" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestCargoRoots(t *testing.T) {
+	dir := t.TempDir()
+	home := filepath.Join(dir, "home")
+	if err := os.MkdirAll(home, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(home, "config.toml"), []byte("[install]\nroot = \"tools\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CARGO_HOME", home)
+	t.Setenv("CARGO_INSTALL_ROOT", "")
+	CargoRoots = []string{filepath.Join(dir, "extra"), home}
+	defer func() { CargoRoots = nil }()
+
+	roots, err := cargoRoots()
+	expected := []string{filepath.Join(dir, "tools"), home, filepath.Join(dir, "extra")}
+	if err != nil || !reflect.DeepEqual(roots, expected) {
+		t.Errorf("got %v, %v", roots, err)
+	}
+}
+
+func TestCargoListSeveralRoots(t *testing.T) {
+	dir := t.TempDir()
+	roots := []string{filepath.Join(dir, "default"), filepath.Join(dir, "tools")}
+	for _, it := range roots {
+		if err := os.MkdirAll(it, 0755); err != nil {
+			t.Fatal(err)
+		}
+		manifest := "[v1]\n\"tool 1.0.0 (path+file:///src/tool)\" = [\"tool\"]\n"
+		if err := ioutil.WriteFile(filepath.Join(it, ".crates.toml"), []byte(manifest), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	found := false
+	p := &Cargo{roots: roots, binstall: &found}
+	pkgs, err := p.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pkgs) != 2 || pkgs[0].Name != "tool" || pkgs[1].Name != "tool@"+roots[1] {
+		t.Fatalf("got %v", pkgs)
+	}
+	expected := "cargo install --force --root " + roots[1] + " --path /src/tool tool"
+	if got := p.UpdateCommand(pkgs[1]); got != expected {
+		t.Errorf("got %q", got)
+	}
+}
@@ -3,6 +3,8 @@ package providers
 import (
 	"reflect"
 	"testing"
+
+	"github.com/casimir/compulsive"
 )
 
 func TestLoadManifest(t *testing.T) {
@@ -36,3 +38,62 @@ func TestLoadManifest(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestUnmarshalInstalls(t *testing.T) {
+	raw := []byte(`{"installs":{
+"ripgrep 14.1.0 (registry+https://github.com/rust-lang/crates.io-index)":{"version_req":null,"bins":["rg"],"features":["pcre2"],"all_features":false,"no_default_features":true,"profile":"release","target":"x86_64-unknown-linux-gnu","rustc":"rustc 1.77.0 (aedd173a2 2024-03-17)\nbinary: rustc\n"},
+"bat 0.24.0 (registry+https://github.com/rust-lang/crates.io-index)":{"version_req":null,"bins":["bat"],"features":[],"all_features":false,"no_default_features":false,"profile":"dev","target":"aarch64-unknown-linux-musl","rustc":"rustc 1.77.0 (aedd173a2 2024-03-17)"}
+}}`)
+	manifest, err := unmarshalInstalls(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest) != 2 || manifest[0].name != "bat" || manifest[1].options.rustc != "rustc 1.77.0 (aedd173a2 2024-03-17)" {
+		t.Fatalf("unexpected manifest: %+v", manifest)
+	}
+	host := "x86_64-unknown-linux-gnu"
+	expected := []string{"--profile", "dev", "--target", "aarch64-unknown-linux-musl"}
+	if got := manifest[0].options.args(host); !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v", got)
+	}
+	expected = []string{"--features", "pcre2", "--no-default-features"}
+	if got := manifest[1].options.args(host); !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestBinstallCommand(t *testing.T) {
+	found := true
+	p := &Cargo{
+		manifest: []cargoManifestEntry{
+			{name: "bat", version: "0.23.0", uri: "registry+https://github.com/rust-lang/crates.io-index", root: "/cargo"},
+			{name: "rg", version: "13.0.0", uri: "registry+https://github.com/rust-lang/crates.io-index", root: "/cargo", options: cargoInstallOptions{features: []string{"pcre2"}}},
+		},
+		roots:    []string{"/cargo"},
+		binstall: &found,
+	}
+	bat := compulsive.Package{Provider: p, Name: "bat", NextVersion: "0.24.0", Attributes: map[string]string{"Root": "/cargo"}}
+	rg := compulsive.Package{Provider: p, Name: "rg", NextVersion: "14.0.0", Attributes: map[string]string{"Root": "/cargo"}}
+	expected := "cargo binstall --no-confirm --force --disable-strategies compile bat@0.24.0 || cargo install --force --version 0.24.0 bat\n" +
+		"cargo install --force --features pcre2 rg"
+	if got := p.UpdateCommand(bat, rg); got != expected {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestBinstallLocalRegistry(t *testing.T) {
+	found := true
+	p := &Cargo{
+		manifest: []cargoManifestEntry{
+			{name: "bat", version: "0.23.0", uri: "registry+https://github.com/rust-lang/crates.io-index", root: "/cargo"},
+		},
+		roots:    []string{"/cargo"},
+		binstall: &found,
+	}
+	bat := compulsive.Package{Provider: p, Name: "bat", NextVersion: "0.24.0", Attributes: map[string]string{"Root": "/cargo"}}
+	defer func() { CargoLocalRegistry = "" }()
+	CargoLocalRegistry = "/registry"
+	if p.useBinstall(bat) {
+		t.Errorf("binstall used with a local registry")
+	}
+}
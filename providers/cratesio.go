@@ -0,0 +1,306 @@
+package providers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/casimir/compulsive"
+)
+
+const (
+	cratesConcurrency = 4
+	// cratesInterval spaces requests, the index being static files served
+	// by a CDN rather than the rate limited web API.
+	cratesInterval = 100 * time.Millisecond
+	cratesRetries  = 3
+	cratesBackoff  = time.Second
+)
+
+var (
+	// CargoIndex is the URL of the sparse registry index used to resolve
+	// crates.io packages, e.g. a local mirror.
+	CargoIndex = "sparse+https://index.crates.io/"
+	// CargoPrereleases allows prereleases to be proposed as updates.
+	CargoPrereleases bool
+)
+
+// cratesIndexEntry is a line of a sparse index file, describing a version.
+type cratesIndexEntry struct {
+	Name    string `json:"name"`
+	Vers    string `json:"vers"`
+	Yanked  bool   `json:"yanked"`
+	PubTime string `json:"pubtime"`
+}
+
+// cratesIndexPath gives the location of the index file of a crate.
+func cratesIndexPath(name string) string {
+	name = strings.ToLower(name)
+	switch len(name) {
+	case 1, 2:
+		return fmt.Sprintf("%d/%s", len(name), name)
+	case 3:
+		return "3/" + name[:1] + "/" + name
+	}
+	return name[:2] + "/" + name[2:4] + "/" + name
+}
+
+func unmarshalIndexEntries(raw []byte) ([]cratesIndexEntry, error) {
+	var entries []cratesIndexEntry
+	scanner := bufio.NewScanner(bytes.NewReader(raw))
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var entry cratesIndexEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// cratesCacheEntry is an index file kept on disk for conditional requests.
+type cratesCacheEntry struct {
+	ETag string `json:"etag"`
+	Body []byte `json:"body"`
+}
+
+// cratesClient queries a sparse registry index on behalf of every provider,
+// spacing and bounding requests and revalidating cached responses.
+type cratesClient struct {
+	baseURL  string
+	token    string
+	client   *http.Client
+	cacheDir string
+	interval time.Duration
+	retries  int
+	backoff  time.Duration
+	sem      chan struct{}
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+func newCratesClient(baseURL, cacheDir string) *cratesClient {
+	return &cratesClient{
+		baseURL:  strings.TrimSuffix(strings.TrimPrefix(baseURL, "sparse+"), "/"),
+		client:   &http.Client{Timeout: 30 * time.Second},
+		cacheDir: cacheDir,
+		interval: cratesInterval,
+		retries:  cratesRetries,
+		backoff:  cratesBackoff,
+		sem:      make(chan struct{}, cratesConcurrency),
+	}
+}
+
+var (
+	cratesOnce   sync.Once
+	sharedCrates *cratesClient
+)
+
+// cratesIO gives the client shared by the providers, caching responses in
+// the user cache directory.
+func cratesIO() *cratesClient {
+	cratesOnce.Do(func() {
+		dir, err := os.UserCacheDir()
+		if err == nil {
+			// responses of the web API, which is no longer queried
+			os.RemoveAll(filepath.Join(dir, "compulsive", "crates.io"))
+			dir = filepath.Join(dir, "compulsive", "index", url.PathEscape(CargoIndex))
+		}
+		sharedCrates = newCratesClient(CargoIndex, dir)
+	})
+	return sharedCrates
+}
+
+// wait blocks until the next request slot.
+func (c *cratesClient) wait() {
+	c.mu.Lock()
+	at := c.next
+	if now := time.Now(); at.Before(now) {
+		at = now
+	}
+	c.next = at.Add(c.interval)
+	c.mu.Unlock()
+	time.Sleep(time.Until(at))
+}
+
+func (c *cratesClient) cachePath(name string) string {
+	if c.cacheDir == "" {
+		return ""
+	}
+	return filepath.Join(c.cacheDir, url.PathEscape(name)+".json")
+}
+
+func (c *cratesClient) readCache(name string) (cratesCacheEntry, bool) {
+	var entry cratesCacheEntry
+	path := c.cachePath(name)
+	if path == "" {
+		return entry, false
+	}
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return entry, false
+	}
+	return entry, json.Unmarshal(raw, &entry) == nil && entry.ETag != ""
+}
+
+func (c *cratesClient) writeCache(name string, entry cratesCacheEntry) {
+	path := c.cachePath(name)
+	if path == "" {
+		return
+	}
+	raw, err := json.Marshal(entry)
+	if err == nil {
+		err = os.MkdirAll(c.cacheDir, 0755)
+	}
+	if err == nil {
+		err = ioutil.WriteFile(path, raw, 0644)
+	}
+	if err != nil {
+		log.Printf("could not cache index response for %q: %s", name, err)
+	}
+}
+
+// do sends a single request, telling whether a failure is worth a retry.
+func (c *cratesClient) do(name string, cached cratesCacheEntry, hasCache bool) ([]byte, bool, error) {
+	req, err := http.NewRequest("GET", c.baseURL+"/"+cratesIndexPath(name), nil)
+	if err != nil {
+		return nil, false, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	if c.token != "" {
+		req.Header.Set("Authorization", c.token)
+	}
+	if hasCache {
+		req.Header.Set("If-None-Match", cached.ETag)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, true, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return nil, true, err
+		}
+		if etag := resp.Header.Get("ETag"); etag != "" {
+			c.writeCache(name, cratesCacheEntry{ETag: etag, Body: body})
+		}
+		return body, false, nil
+	case resp.StatusCode == http.StatusNotModified && hasCache:
+		return cached.Body, false, nil
+	case resp.StatusCode == http.StatusUnauthorized:
+		return nil, false, fmt.Errorf("registry requires a token")
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return nil, false, fmt.Errorf("crate not found in index")
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return nil, true, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return nil, false, fmt.Errorf("unexpected status: %s", resp.Status)
+}
+
+// get fetches the index file of a crate, retrying transient failures with an
+// exponential backoff.
+func (c *cratesClient) get(name string) ([]byte, error) {
+	c.sem <- struct{}{}
+	defer func() { <-c.sem }()
+	cached, hasCache := c.readCache(name)
+	backoff := c.backoff
+	var err error
+	for attempt := 0; attempt <= c.retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		c.wait()
+		var body []byte
+		var retry bool
+		body, retry, err = c.do(name, cached, hasCache)
+		if err == nil {
+			return body, nil
+		}
+		if !retry {
+			break
+		}
+	}
+	return nil, err
+}
+
+func (c *cratesClient) versions(name string) ([]cratesIndexEntry, error) {
+	raw, err := c.get(name)
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalIndexEntries(raw)
+}
+
+// indexReleases gives the releases of a crate that can be proposed as
+// updates, prereleases being excluded unless allowed or already installed.
+func indexReleases(entries []cratesIndexEntry, current string) ([]release, string) {
+	var releases []release
+	latest := ""
+	allowPre := CargoPrereleases || isPrerelease(current)
+	for _, it := range entries {
+		if it.Yanked || (isPrerelease(it.Vers) && !allowPre) {
+			continue
+		}
+		rel := release{version: it.Vers}
+		if it.PubTime != "" {
+			rel.date, _ = time.Parse(time.RFC3339, it.PubTime)
+		}
+		releases = append(releases, rel)
+		if latest == "" || compulsive.CompareVersions(it.Vers, latest) > 0 {
+			latest = it.Vers
+		}
+	}
+	return releases, latest
+}
+
+// crateDescription gives the description of an installed crate from the
+// sources cargo downloaded it as, the index not describing crates.
+func crateDescription(name, version string) string {
+	home, err := cargoHome()
+	if err != nil {
+		return ""
+	}
+	manifests, _ := filepath.Glob(filepath.Join(home, "registry", "src", "*", name+"-"+version, "Cargo.toml"))
+	for _, it := range manifests {
+		if raw, err := ioutil.ReadFile(it); err == nil {
+			return unmarshalPackageField(raw, "description")
+		}
+	}
+	return ""
+}
+
+// fetchPkgInfos fills the packages from the index concurrently, uris being
+// the sources of the packages. Failures are reported per crate.
+func fetchPkgInfos(uris []string, pkgs []compulsive.Package) {
+	var wg sync.WaitGroup
+	for i := range pkgs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := fetchPkgInfo(uris[i], &pkgs[i]); err != nil {
+				log.Printf("failed to fetch data for package %q: %s", pkgs[i].Name, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,108 @@
+package providers
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCratesIndexPath(t *testing.T) {
+	cases := map[string]string{
+		"a":       "1/a",
+		"ab":      "2/ab",
+		"abc":     "3/a/abc",
+		"Ripgrep": "ri/pg/ripgrep",
+	}
+	for name, expected := range cases {
+		if got := cratesIndexPath(name); got != expected {
+			t.Errorf("cratesIndexPath(%q) = %q", name, got)
+		}
+	}
+}
+
+func TestIndexReleases(t *testing.T) {
+	entries, err := unmarshalIndexEntries([]byte(`{"name":"tool","vers":"1.0.0","yanked":false}
+{"name":"tool","vers":"1.1.0","yanked":true}
+{"name":"tool","vers":"1.0.1","yanked":false,"pubtime":"2024-05-01T10:00:00Z"}
+{"name":"tool","vers":"2.0.0-beta.1","yanked":false}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	releases, latest := indexReleases(entries, "1.0.0")
+	if latest != "1.0.1" || len(releases) != 2 || releases[1].date.Day() != 1 {
+		t.Errorf("got %q %v", latest, releases)
+	}
+	if _, latest := indexReleases(entries, "2.0.0-alpha"); latest != "2.0.0-beta.1" {
+		t.Errorf("prerelease not proposed to a prerelease install: %q", latest)
+	}
+}
+
+func TestCratesClient(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.Header.Get("User-Agent") != userAgent {
+			t.Errorf("missing user agent")
+		}
+		switch r.URL.Path {
+		case "/fl/ak/flaky":
+			if calls == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte(`{"name":"flaky","vers":"1.0.0"}` + "\n"))
+		case "/ca/ch/cached":
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", `"v1"`)
+			w.Write([]byte(`{"name":"cached","vers":"1.0.0"}` + "\n" + `{"name":"cached","vers":"2.0.0"}` + "\n"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	dir, err := ioutil.TempDir("", "crates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	client := newCratesClient("sparse+"+srv.URL+"/", dir)
+	client.interval, client.backoff = 0, 0
+
+	if entries, err := client.versions("flaky"); err != nil || len(entries) != 1 || calls != 2 {
+		t.Errorf("retry failed: %v, %v after %d calls", entries, err, calls)
+	}
+	for i := 0; i < 2; i++ {
+		if entries, err := client.versions("cached"); err != nil || len(entries) != 2 {
+			t.Errorf("attempt %d: %v, %v", i, entries, err)
+		}
+	}
+	if _, err := client.versions("missing"); err == nil {
+		t.Errorf("expected an error for a missing crate")
+	}
+}
+
+func TestCrateDescription(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("CARGO_HOME", home)
+	dir := filepath.Join(home, "registry", "src", "index.crates.io-6f17d22bba15001f", "ripgrep-14.1.0")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	manifest := "[package]\nname = \"ripgrep\"\nversion = \"14.1.0\"\ndescription = \"Line-oriented search tool\"\n\n[dependencies]\ndescription = \"not this one\"\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "Cargo.toml"), []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := crateDescription("ripgrep", "14.1.0"); got != "Line-oriented search tool" {
+		t.Errorf("got %q", got)
+	}
+	if got := crateDescription("ripgrep", "13.0.0"); got != "" {
+		t.Errorf("got %q for a version without sources", got)
+	}
+}
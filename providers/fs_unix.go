@@ -0,0 +1,15 @@
+//go:build !windows
+
+package providers
+
+import "syscall"
+
+// accessWrite is W_OK from unistd.h.
+const accessWrite = 0x2
+
+// isWritable tells whether the current user can create files in dir, a
+// missing dir being created when needed.
+func isWritable(dir string) bool {
+	err := syscall.Access(dir, accessWrite)
+	return err == nil || err == syscall.ENOENT
+}
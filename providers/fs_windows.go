@@ -0,0 +1,7 @@
+package providers
+
+// isWritable tells whether the current user can create files in dir, which
+// is always assumed as there is no root user to escalate to.
+func isWritable(dir string) bool {
+	return true
+}
@@ -1,83 +1,72 @@
 package providers
 
 import (
-	"bytes"
-	"encoding/json"
-	"fmt"
+	"debug/buildinfo"
 	"io/ioutil"
-	"os"
+	"log"
 	"os/exec"
 	"path/filepath"
 	"regexp"
 	"runtime"
 	"strings"
-	"time"
 
 	"github.com/casimir/compulsive"
 )
 
-type goPkgInfo struct {
-	ImportPath  string
-	Name        string
-	Target      string
-	Stale       bool
-	StaleReason string
-}
-
-func loadPackages(p *Go) ([]goPkgInfo, error) {
-	out, err := exec.Command("go", "list", "-json", "all").Output()
+func goEnv(name string) string {
+	out, err := exec.Command("go", "env", name).Output()
 	if err != nil {
-		return nil, fmt.Errorf("error while fetching packages: %s", err)
-	}
-	var pkgs []goPkgInfo
-	dec := json.NewDecoder(bytes.NewReader(out))
-	for dec.More() {
-		var pkg goPkgInfo
-		if err := dec.Decode(&pkg); err != nil {
-			return nil, fmt.Errorf("failed to decode package info: %s", err)
-		}
-		pkgs = append(pkgs, pkg)
-
+		return ""
 	}
-	return pkgs, nil
+	return strings.TrimSpace(string(out))
 }
 
-func loadMainPackages(p *Go) ([]goPkgInfo, error) {
-	allPkgs, err := loadPackages(p)
-	if err != nil {
-		return nil, err
-	}
-	var pkgs []goPkgInfo
-	for _, it := range allPkgs {
-		if it.Name == "main" {
-			pkgs = append(pkgs, it)
-		}
-	}
-	return pkgs, nil
+// goToolchain gives the version of the installed Go toolchain, e.g. go1.22.1.
+func goToolchain() string {
+	return goEnv("GOVERSION")
 }
 
-type binary struct {
-	provider compulsive.Provider
-	command  string
-	modTime  time.Time
-	info     goPkgInfo
+// isToolchainOlder tells whether a binary built with toolchain predates the
+// installed one.
+func isToolchainOlder(toolchain, installed string) bool {
+	if !strings.HasPrefix(toolchain, "go") || !strings.HasPrefix(installed, "go") {
+		return false
+	}
+	return compulsive.CompareVersions(toolchain[2:], installed[2:]) < 0
 }
 
-func (b binary) name() string {
-	if b.info.ImportPath != "" {
-		return b.info.ImportPath
+// buildAttributes describes how a binary was built from its embedded build
+// information.
+func buildAttributes(info *buildinfo.BuildInfo) map[string]string {
+	attrs := map[string]string{"Toolchain": info.GoVersion}
+	if info.Main.Path != "" {
+		attrs["Module"] = info.Main.Path
+		attrs["Module version"] = info.Main.Version
 	}
-	return b.command
+	return attrs
+}
+
+type goLatestModule struct {
+	path    string
+	version goModuleVersion
+	err     error
 }
 
 type Go struct {
-	path string
+	binDirs   []string
+	toolchain string
+	proxy     *goProxyClient
+	latest    map[string]goLatestModule
 }
 
 func (p *Go) Name() string {
 	return "go"
 }
 
+func (p *Go) Ecosystem() string {
+	return "Go"
+}
+
 func (p *Go) IsAvailable() bool {
 	out, err := exec.Command("go", "version").Output()
 	if err != nil {
@@ -92,58 +81,188 @@ func (p *Go) Sync() error {
 	return nil
 }
 
-func (p *Go) List() ([]compulsive.Package, error) {
-	binPath := filepath.Join(p.path, "bin")
-	binaries, _ := ioutil.ReadDir(binPath)
-	pkgsInfo, err := loadMainPackages(p)
-	if err != nil {
-		return nil, err
+// BinaryDirs gives GOBIN if set, followed by the bin directory of every
+// GOPATH entry, the first directory being where go install writes binaries.
+func (p *Go) BinaryDirs() []string {
+	if p.binDirs != nil {
+		return p.binDirs
 	}
+	var dirs []string
+	if gobin := goEnv("GOBIN"); gobin != "" {
+		dirs = append(dirs, gobin)
+	}
+	for _, it := range filepath.SplitList(goEnv("GOPATH")) {
+		// recent toolchains report the default location when GOBIN is unset
+		if bin := filepath.Join(it, "bin"); it != "" && (len(dirs) == 0 || bin != dirs[0]) {
+			dirs = append(dirs, bin)
+		}
+	}
+	p.binDirs = dirs
+	return p.binDirs
+}
+
+func (p *Go) NeedsRoot(op compulsive.Operation) bool {
+	if op != compulsive.OpUpdate {
+		return false
+	}
+	for _, it := range p.BinaryDirs() {
+		if !isWritable(it) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Go) listDir(dir string) []compulsive.Package {
+	entries, _ := ioutil.ReadDir(dir)
 	var pkgs []compulsive.Package
-	for _, it := range binaries {
-		filename := it.Name()
-		bin := binary{
-			provider: p,
-			command:  filename,
-			modTime:  it.ModTime(),
+	for _, it := range entries {
+		if it.IsDir() {
+			continue
 		}
+		command := it.Name()
 		if runtime.GOOS == "windows" {
-			bin.command = bin.command[:len(bin.command)-4]
-		}
-		target := filepath.Join(binPath, filename)
-		for _, pi := range pkgsInfo {
-			if pi.Target == target {
-				bin.info = pi
-				break
-			}
+			command = strings.TrimSuffix(command, ".exe")
 		}
 		pkg := compulsive.Package{
-			Provider:    p,
-			Name:        bin.name(),
-			Label:       bin.command,
-			Binaries:    []string{bin.command},
-			State:       compulsive.StateUpToDate,
-			Version:     bin.modTime.Format("2006-01-02"),
-			NextVersion: time.Now().Format("2006-01-02"),
+			Provider:   p,
+			Name:       command,
+			Label:      command,
+			Binaries:   []string{command},
+			State:      compulsive.StateUnknown,
+			Attributes: map[string]string{"Directory": dir},
+		}
+		info, err := buildinfo.ReadFile(filepath.Join(dir, it.Name()))
+		if err != nil {
+			pkgs = append(pkgs, pkg)
+			continue
 		}
-		if bin.info.ImportPath == "" {
-			pkg.State = compulsive.StateUnknown
-		} else if bin.info.Stale {
-			pkg.State = compulsive.StateOutdated
+		pkg.Name = info.Path
+		pkg.Version = info.Main.Version
+		pkg.Attributes = buildAttributes(info)
+		pkg.Attributes["Directory"] = dir
+		released := pkg.Version != "" && pkg.Version != "(devel)"
+		if released {
+			p.checkLatest(&pkg, info.Main.Path)
+		}
+		if isToolchainOlder(info.GoVersion, p.toolchain) {
+			pkg.Attributes["Rebuild needed"] = "built with " + info.GoVersion + ", " + p.toolchain + " installed"
+			if released {
+				pkg.State = compulsive.StateOutdated
+			}
 		}
 		pkgs = append(pkgs, pkg)
 	}
+	return pkgs
+}
+
+// lookupLatest gives the latest version of a module, the lookup being done
+// once for all the binaries built from it.
+func (p *Go) lookupLatest(module string) goLatestModule {
+	if latest, ok := p.latest[module]; ok {
+		return latest
+	}
+	var latest goLatestModule
+	latest.path, latest.version, latest.err = p.proxy.latestMajor(module)
+	p.latest[module] = latest
+	return latest
+}
+
+// checkLatest sets the next version of a package, a newer major version of
+// its module making it outdated as well.
+func (p *Go) checkLatest(pkg *compulsive.Package, module string) {
+	latest := p.lookupLatest(module)
+	if latest.err != nil {
+		log.Printf("failed to fetch latest version of %q: %s", module, latest.err)
+		return
+	}
+	pkg.NextVersion = latest.version.Version
+	pkg.Released = latest.version.Time
+	pkg.State = compulsive.StateUpToDate
+	if latest.path != module {
+		pkg.Attributes["Latest module"] = latest.path
+		pkg.State = compulsive.StateOutdated
+	} else if compulsive.CompareVersions(pkg.NextVersion, pkg.Version) > 0 {
+		pkg.State = compulsive.StateOutdated
+	}
+}
+
+func (p *Go) List() ([]compulsive.Package, error) {
+	if p.toolchain == "" {
+		p.toolchain = goToolchain()
+	}
+	if p.proxy == nil {
+		p.proxy = newGoProxyClient()
+	}
+	p.latest = make(map[string]goLatestModule)
+	seen := make(map[string]bool)
+	var pkgs []compulsive.Package
+	for _, dir := range p.BinaryDirs() {
+		for _, it := range p.listDir(dir) {
+			if seen[it.Name] {
+				// the binary is also installed in a directory listed before
+				it.Attributes["Package"] = it.Name
+				it.Name = it.Name + "@" + dir
+			}
+			seen[it.Name] = true
+			pkgs = append(pkgs, it)
+		}
+	}
 	return pkgs, nil
 }
 
+// installCommand gives the go install command for a package, binaries
+// living outside of the default directory being installed back in place.
+func (p *Go) installCommand(pkg compulsive.Package, path, version string) string {
+	command := "go install " + path + "@" + version
+	dirs := p.BinaryDirs()
+	if dir := pkg.Attributes["Directory"]; dir != "" && len(dirs) > 0 && dir != dirs[0] {
+		if runtime.GOOS == "windows" {
+			// set only changes the environment of the commands that follow
+			command = `set "GOBIN=` + dir + `" && ` + command
+		} else {
+			command = "GOBIN=" + shellQuote(dir) + " " + command
+		}
+	}
+	return command
+}
+
+// goPackagePath gives the package path a binary is built from, which
+// differs from the package name when it is installed in several directories.
+func goPackagePath(pkg compulsive.Package) string {
+	if path := pkg.Attributes["Package"]; path != "" {
+		return path
+	}
+	return pkg.Name
+}
+
+// upgradePath gives the package path to install for an upgrade, following
+// the move of its module to a newer major version.
+func upgradePath(pkg compulsive.Package) string {
+	path := goPackagePath(pkg)
+	module, latest := pkg.Attributes["Module"], pkg.Attributes["Latest module"]
+	if latest == "" || !strings.HasPrefix(path, module) {
+		return path
+	}
+	return latest + strings.TrimPrefix(path, module)
+}
+
+func (p *Go) InstallVersionCommand(pkg compulsive.Package, version string) string {
+	return p.installCommand(pkg, goPackagePath(pkg), version)
+}
+
 func (p *Go) UpdateCommand(pkgs ...compulsive.Package) string {
 	var commands []string
 	for _, it := range pkgs {
-		commands = append(commands, "go get "+it.Name)
+		version := it.NextVersion
+		if version == "" {
+			version = "latest"
+		}
+		commands = append(commands, p.installCommand(it, upgradePath(it), version))
 	}
 	return strings.Join(commands, "\n")
 }
 
 func NewGo() compulsive.Provider {
-	return &Go{path: os.Getenv("GOPATH")}
+	return &Go{}
 }
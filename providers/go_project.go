@@ -0,0 +1,91 @@
+package providers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/casimir/compulsive"
+)
+
+type goModuleInfo struct {
+	Path     string
+	Version  string
+	Main     bool
+	Indirect bool
+	Update   *struct {
+		Version string
+	}
+}
+
+type GoProject struct {
+	root string
+}
+
+func (p *GoProject) Name() string {
+	return "go"
+}
+
+func (p *GoProject) Ecosystem() string {
+	return "Go"
+}
+
+func (p *GoProject) IsAvailable() bool {
+	return fileExists(filepath.Join(p.root, "go.mod")) && (&Go{}).IsAvailable()
+}
+
+func (p *GoProject) Sync() error {
+	return nil
+}
+
+func (p *GoProject) List() ([]compulsive.Package, error) {
+	cmd := exec.Command("go", "list", "-m", "-u", "-json", "all")
+	cmd.Dir = p.root
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("error while fetching modules: %s", err)
+	}
+	var pkgs []compulsive.Package
+	dec := json.NewDecoder(bytes.NewReader(out))
+	for dec.More() {
+		var mod goModuleInfo
+		if err := dec.Decode(&mod); err != nil {
+			return nil, fmt.Errorf("failed to decode module info: %s", err)
+		}
+		if mod.Main || mod.Indirect {
+			continue
+		}
+		pkg := compulsive.Package{
+			Provider: p,
+			Name:     mod.Path,
+			Label:    mod.Path,
+			State:    compulsive.StateUpToDate,
+			Version:  mod.Version,
+		}
+		if mod.Update != nil {
+			pkg.NextVersion = mod.Update.Version
+			pkg.State = compulsive.StateOutdated
+		}
+		pkgs = append(pkgs, pkg)
+	}
+	return pkgs, nil
+}
+
+func (p *GoProject) UpdateCommand(pkgs ...compulsive.Package) string {
+	var modules []string
+	for _, it := range pkgs {
+		version := it.NextVersion
+		if version == "" {
+			version = "latest"
+		}
+		modules = append(modules, it.Name+"@"+version)
+	}
+	return "go -C " + shellQuote(p.root) + " get " + strings.Join(modules, " ")
+}
+
+func NewGoProject(root string) compulsive.Provider {
+	return &GoProject{root: root}
+}
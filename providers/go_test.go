@@ -0,0 +1,134 @@
+package providers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/casimir/compulsive"
+)
+
+func TestIsToolchainOlder(t *testing.T) {
+	cases := []struct {
+		toolchain, installed string
+		expected             bool
+	}{
+		{"go1.20.1", "go1.22.0", true},
+		{"go1.22.0", "go1.22.0", false},
+		{"go1.21rc2", "go1.21.0", true},
+		{"devel", "go1.22.0", false},
+	}
+	for _, it := range cases {
+		if got := isToolchainOlder(it.toolchain, it.installed); got != it.expected {
+			t.Errorf("isToolchainOlder(%q, %q) = %v", it.toolchain, it.installed, got)
+		}
+	}
+}
+
+func TestGoBinaryDirs(t *testing.T) {
+	dir := t.TempDir()
+	first, second := filepath.Join(dir, "first"), filepath.Join(dir, "second")
+	t.Setenv("GOENV", "off")
+	t.Setenv("GOPATH", first+string(os.PathListSeparator)+second)
+	t.Setenv("GOBIN", filepath.Join(dir, "bin"))
+	expected := []string{filepath.Join(dir, "bin"), filepath.Join(first, "bin"), filepath.Join(second, "bin")}
+	if got := (&Go{}).BinaryDirs(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v", got)
+	}
+	t.Setenv("GOBIN", filepath.Join(first, "bin"))
+	expected = []string{filepath.Join(first, "bin"), filepath.Join(second, "bin")}
+	if got := (&Go{}).BinaryDirs(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestGoListDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "script"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "subdir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// the test binary is a Go binary built from this package
+	self, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := ioutil.ReadFile(self)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "tool"), raw, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	p := &Go{toolchain: "go99.0", latest: make(map[string]goLatestModule)}
+	pkgs := p.listDir(dir)
+	if len(pkgs) != 2 {
+		t.Fatalf("got %v", pkgs)
+	}
+	if pkgs[0].Name != "script" || pkgs[0].Version != "" || pkgs[0].Attributes["Directory"] != dir {
+		t.Errorf("got %+v", pkgs[0])
+	}
+	tool := pkgs[1]
+	if tool.Label != "tool" || tool.Name == "tool" || tool.Attributes["Directory"] != dir {
+		t.Errorf("got %+v", tool)
+	}
+	if tool.Attributes["Rebuild needed"] == "" || tool.State != compulsive.StateUnknown {
+		t.Errorf("devel build should need a rebuild without being outdated: %+v", tool)
+	}
+}
+
+func TestGoListSeveralDirs(t *testing.T) {
+	self, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := ioutil.ReadFile(self)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, second := t.TempDir(), t.TempDir()
+	for _, dir := range []string{first, second} {
+		if err := ioutil.WriteFile(filepath.Join(dir, "tool"), raw, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p := &Go{binDirs: []string{first, second}, toolchain: "go99.0", proxy: &goProxyClient{}}
+	pkgs, err := p.List()
+	if err != nil || len(pkgs) != 2 {
+		t.Fatalf("got %v, %v", pkgs, err)
+	}
+	path := pkgs[0].Name
+	if pkgs[1].Name != path+"@"+second || pkgs[1].Attributes["Package"] != path {
+		t.Errorf("got %+v", pkgs[1])
+	}
+	if got := p.InstallVersionCommand(pkgs[1], "v1.0.0"); got != "GOBIN="+second+" go install "+path+"@v1.0.0" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestGoInstallCommand(t *testing.T) {
+	p := &Go{binDirs: []string{"/go/bin", "/opt/go/bin"}}
+	pkg := compulsive.Package{
+		Provider:   p,
+		Name:       "example.com/tool/cmd/tool",
+		Attributes: map[string]string{"Directory": "/go/bin", "Module": "example.com/tool", "Latest module": "example.com/tool/v2"},
+	}
+	if got := p.installCommand(pkg, upgradePath(pkg), "v2.0.0"); got != "go install example.com/tool/v2/cmd/tool@v2.0.0" {
+		t.Errorf("got %q", got)
+	}
+	pkg.Attributes["Directory"] = "/opt/go/bin"
+	if got := p.InstallVersionCommand(pkg, "v1.2.0"); got != "GOBIN=/opt/go/bin go install example.com/tool/cmd/tool@v1.2.0" {
+		t.Errorf("got %q", got)
+	}
+	p.binDirs[1] = "/opt/my tools"
+	pkg.Attributes["Directory"] = "/opt/my tools"
+	if got := p.InstallVersionCommand(pkg, "v1.2.0"); got != "GOBIN='/opt/my tools' go install example.com/tool/cmd/tool@v1.2.0" {
+		t.Errorf("got %q", got)
+	}
+}
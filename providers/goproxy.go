@@ -0,0 +1,270 @@
+package providers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/casimir/compulsive"
+)
+
+var (
+	errGoProxyNotFound = errors.New("module not found on proxy")
+	errGoProxyOff      = errors.New("module lookup disabled by GOPROXY=off")
+	errGoProxyDirect   = errors.New("module must be fetched directly")
+
+	goMajorRe = regexp.MustCompile(`^(.+)/v(\d+)$`)
+)
+
+// goModuleVersion is the payload of the .info and @latest endpoints of the
+// module proxy protocol.
+type goModuleVersion struct {
+	Version string
+	Time    time.Time
+}
+
+type goProxy struct {
+	url string
+	// anyError tells whether the next proxy is tried on any error, rather
+	// than only when the module is not found.
+	anyError bool
+}
+
+// parseGoProxy reads a GOPROXY value, entries being separated by a comma
+// when falling back on missing modules only and by a pipe otherwise.
+func parseGoProxy(value string) []goProxy {
+	if value == "" {
+		value = "https://proxy.golang.org,direct"
+	}
+	var proxies []goProxy
+	for value != "" {
+		entry, sep := value, byte(0)
+		if i := strings.IndexAny(value, ",|"); i >= 0 {
+			entry, sep, value = value[:i], value[i], value[i+1:]
+		} else {
+			value = ""
+		}
+		if entry = strings.TrimSpace(entry); entry != "" {
+			proxies = append(proxies, goProxy{url: entry, anyError: sep == '|'})
+		}
+	}
+	return proxies
+}
+
+// escapeModulePath applies the case encoding of the module proxy protocol,
+// upper case letters being replaced by an exclamation mark and the letter in
+// lower case.
+func escapeModulePath(s string) string {
+	var buf strings.Builder
+	for _, r := range s {
+		if 'A' <= r && r <= 'Z' {
+			buf.WriteByte('!')
+			r += 'a' - 'A'
+		}
+		buf.WriteRune(r)
+	}
+	return buf.String()
+}
+
+// matchGoPatterns tells whether a module path matches one of the comma
+// separated glob patterns of GOPRIVATE-like variables, a pattern matching
+// any path it is a prefix of.
+func matchGoPatterns(patterns, module string) bool {
+	for _, it := range strings.Split(patterns, ",") {
+		pattern := strings.TrimSpace(it)
+		if pattern == "" {
+			continue
+		}
+		n := strings.Count(pattern, "/") + 1
+		elems := strings.SplitN(module, "/", n+1)
+		if len(elems) < n {
+			continue
+		}
+		prefix := strings.Join(elems[:n], "/")
+		if matched, _ := path.Match(pattern, prefix); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// splitMajor separates the major version suffix of a module path, a path
+// without suffix being a v0 or v1 module.
+func splitMajor(module string) (string, int) {
+	if matches := goMajorRe.FindStringSubmatch(module); matches != nil {
+		if major, err := strconv.Atoi(matches[2]); err == nil && major >= 2 {
+			return matches[1], major
+		}
+	}
+	return module, 1
+}
+
+func fetchGoProxy(client *http.Client, base, endpoint string) ([]byte, error) {
+	if strings.HasPrefix(base, "file://") {
+		u, err := url.Parse(base)
+		if err != nil {
+			return nil, err
+		}
+		raw, err := ioutil.ReadFile(filepath.FromSlash(u.Path + endpoint))
+		if os.IsNotExist(err) {
+			return nil, errGoProxyNotFound
+		}
+		return raw, err
+	}
+	req, err := newRequest(strings.TrimSuffix(base, "/") + endpoint)
+	if err != nil {
+		return nil, err
+	}
+	// a timeout is an error like any other, falling back to the next proxy
+	// of a pipe separated list
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return ioutil.ReadAll(resp.Body)
+	case http.StatusNotFound, http.StatusGone:
+		return nil, errGoProxyNotFound
+	}
+	return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+}
+
+// goProxyClient resolves module versions as the go command would, from the
+// proxies of GOPROXY or directly for the modules matching GONOPROXY (which
+// defaults to GOPRIVATE).
+type goProxyClient struct {
+	proxies []goProxy
+	noProxy string
+	client  *http.Client
+}
+
+func newGoProxyClient() *goProxyClient {
+	return &goProxyClient{
+		proxies: parseGoProxy(goEnv("GOPROXY")),
+		noProxy: goEnv("GONOPROXY"),
+		client:  httpClient,
+	}
+}
+
+// get fetches an endpoint of a module from the proxies, errGoProxyDirect
+// telling the module must be looked up directly. Without allowDirect, such
+// modules are reported as not found instead.
+func (c *goProxyClient) get(module, endpoint string, allowDirect bool) ([]byte, error) {
+	direct := errGoProxyDirect
+	if !allowDirect {
+		direct = errGoProxyNotFound
+	}
+	if matchGoPatterns(c.noProxy, module) {
+		return nil, direct
+	}
+	client := c.client
+	if client == nil {
+		client = httpClient
+	}
+	err := errGoProxyNotFound
+	for _, it := range c.proxies {
+		switch it.url {
+		case "off":
+			return nil, errGoProxyOff
+		case "direct":
+			return nil, direct
+		}
+		var raw []byte
+		raw, err = fetchGoProxy(client, it.url, "/"+escapeModulePath(module)+endpoint)
+		if err == nil {
+			return raw, nil
+		}
+		if err != errGoProxyNotFound && !it.anyError {
+			return nil, err
+		}
+	}
+	return nil, err
+}
+
+// latestDirect delegates the lookup to the go command, which knows how to
+// reach version control systems and applies GOFLAGS.
+var latestDirect = func(module string) (goModuleVersion, error) {
+	var mv goModuleVersion
+	cmd := exec.Command("go", "list", "-m", "-json", module+"@latest")
+	cmd.Dir = os.TempDir()
+	cmd.Env = append(os.Environ(), "GOPROXY=direct")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if strings.Contains(stderr.String(), "not found") || strings.Contains(stderr.String(), "no matching versions") {
+			return mv, errGoProxyNotFound
+		}
+		return mv, fmt.Errorf("%s: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	err = json.Unmarshal(out, &mv)
+	return mv, err
+}
+
+// latest gives the highest release of a module, or what the proxy reports
+// as latest when no release was tagged. Modules not served by the proxies
+// are looked up directly only with allowDirect.
+func (c *goProxyClient) latest(module string, allowDirect bool) (goModuleVersion, error) {
+	var mv goModuleVersion
+	raw, err := c.get(module, "/@v/list", allowDirect)
+	if err == errGoProxyDirect {
+		return latestDirect(module)
+	} else if err != nil {
+		return mv, err
+	}
+	for _, it := range strings.Fields(string(raw)) {
+		if isPrerelease(it) {
+			continue
+		}
+		if mv.Version == "" || compulsive.CompareVersions(it, mv.Version) > 0 {
+			mv.Version = it
+		}
+	}
+	endpoint := "/@latest"
+	if mv.Version != "" {
+		endpoint = "/@v/" + escapeModulePath(mv.Version) + ".info"
+	}
+	raw, err = c.get(module, endpoint, allowDirect)
+	if err != nil {
+		if mv.Version != "" {
+			return mv, nil
+		}
+		return mv, err
+	}
+	err = json.Unmarshal(raw, &mv)
+	return mv, err
+}
+
+// latestMajor gives the latest version of a module, looking for newer major
+// versions published under a /vN path. Major versions are only looked for
+// on the proxies, probing a missing path directly meaning a VCS fetch.
+func (c *goProxyClient) latestMajor(module string) (string, goModuleVersion, error) {
+	mv, err := c.latest(module, true)
+	if err != nil || strings.HasPrefix(module, "gopkg.in/") {
+		return module, mv, err
+	}
+	base, major := splitMajor(module)
+	for next := major + 1; ; next++ {
+		candidate := base + "/v" + strconv.Itoa(next)
+		cmv, err := c.latest(candidate, false)
+		if err != nil || cmv.Version == "" {
+			break
+		}
+		module, mv = candidate, cmv
+	}
+	return module, mv, nil
+}
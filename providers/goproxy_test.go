@@ -0,0 +1,148 @@
+package providers
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseGoProxy(t *testing.T) {
+	expected := []goProxy{
+		{url: "https://athens.example.com", anyError: true},
+		{url: "https://proxy.golang.org"},
+		{url: "direct"},
+	}
+	if got := parseGoProxy("https://athens.example.com|https://proxy.golang.org,direct"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v", got)
+	}
+	if got := parseGoProxy(""); len(got) != 2 || got[1].url != "direct" {
+		t.Errorf("unexpected default: %v", got)
+	}
+}
+
+func TestEscapeModulePath(t *testing.T) {
+	if got := escapeModulePath("github.com/BurntSushi/toml"); got != "github.com/!burnt!sushi/toml" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestMatchGoPatterns(t *testing.T) {
+	cases := []struct {
+		module   string
+		expected bool
+	}{
+		{"git.corp.example.com/tools/lint", true},
+		{"github.com/acme/private", true},
+		{"github.com/acme", false},
+		{"github.com/other/public", false},
+	}
+	for _, it := range cases {
+		if got := matchGoPatterns("*.corp.example.com, github.com/acme/*", it.module); got != it.expected {
+			t.Errorf("matchGoPatterns(%q) = %v", it.module, got)
+		}
+	}
+}
+
+func TestSplitMajor(t *testing.T) {
+	if base, major := splitMajor("github.com/acme/tool/v3"); base != "github.com/acme/tool" || major != 3 {
+		t.Errorf("got %q %d", base, major)
+	}
+	if base, major := splitMajor("github.com/acme/tool"); base != "github.com/acme/tool" || major != 1 {
+		t.Errorf("got %q %d", base, major)
+	}
+}
+
+func writeProxyFile(t *testing.T, root, name, content string) {
+	path := filepath.Join(root, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLatestMajor(t *testing.T) {
+	root, err := ioutil.TempDir("", "goproxy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	writeProxyFile(t, root, "example.com/!tool/@v/list", "v1.0.0\nv1.2.0\nv1.3.0-rc1\n")
+	writeProxyFile(t, root, "example.com/!tool/@v/v1.2.0.info", `{"Version":"v1.2.0","Time":"2024-01-02T00:00:00Z"}`)
+	writeProxyFile(t, root, "example.com/!tool/v2/@v/list", "v2.1.0\n")
+	writeProxyFile(t, root, "example.com/!tool/v2/@v/v2.1.0.info", `{"Version":"v2.1.0","Time":"2024-03-04T00:00:00Z"}`)
+
+	client := &goProxyClient{proxies: parseGoProxy("file://" + filepath.ToSlash(root) + ",off")}
+	mv, err := client.latest("example.com/Tool", true)
+	if err != nil || mv.Version != "v1.2.0" || mv.Time.Day() != 2 {
+		t.Errorf("got %v, %v", mv, err)
+	}
+	module, mv, err := client.latestMajor("example.com/Tool")
+	if err != nil || module != "example.com/Tool/v2" || mv.Version != "v2.1.0" {
+		t.Errorf("got %q %v, %v", module, mv, err)
+	}
+	if _, err := client.latest("example.com/missing", true); err != errGoProxyOff {
+		t.Errorf("expected lookup to stop at off, got %v", err)
+	}
+}
+
+func TestLatestMajorStaysOnProxy(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/example.com/tool/@v/list":
+			w.Write([]byte("v1.0.0\n"))
+		case "/example.com/tool/@v/v1.0.0.info":
+			w.Write([]byte(`{"Version":"v1.0.0"}`))
+		default:
+			w.WriteHeader(http.StatusGone)
+		}
+	}))
+	defer srv.Close()
+	lookups := 0
+	defer func(f func(string) (goModuleVersion, error)) { latestDirect = f }(latestDirect)
+	latestDirect = func(module string) (goModuleVersion, error) {
+		lookups++
+		return goModuleVersion{}, errGoProxyNotFound
+	}
+
+	client := &goProxyClient{proxies: parseGoProxy(srv.URL + ",direct")}
+	module, mv, err := client.latestMajor("example.com/tool")
+	if err != nil || module != "example.com/tool" || mv.Version != "v1.0.0" {
+		t.Errorf("got %q %v, %v", module, mv, err)
+	}
+	if lookups != 0 {
+		t.Errorf("major version probe looked up %d modules directly", lookups)
+	}
+}
+
+func TestGoProxyTimeout(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Second)
+	}))
+	defer slow.Close()
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("User-Agent") != userAgent {
+			t.Errorf("missing user agent")
+		}
+		w.Write([]byte("v1.0.0\n"))
+	}))
+	defer fast.Close()
+
+	client := &goProxyClient{
+		proxies: parseGoProxy(slow.URL + "|" + fast.URL),
+		client:  &http.Client{Timeout: 50 * time.Millisecond},
+	}
+	if raw, err := client.get("example.com/tool", "/@v/list", false); err != nil || string(raw) != "v1.0.0\n" {
+		t.Errorf("got %q, %v", raw, err)
+	}
+	client.proxies = parseGoProxy(slow.URL + "," + fast.URL)
+	if _, err := client.get("example.com/tool", "/@v/list", false); err == nil || err == errGoProxyNotFound {
+		t.Errorf("expected the timeout to be reported, got %v", err)
+	}
+}
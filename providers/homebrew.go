@@ -3,19 +3,27 @@ package providers
 import (
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
 	"os/exec"
+	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/casimir/compulsive"
 )
 
 type brewPkgInfo struct {
-	provider compulsive.Provider
-	Name     string `json:"name"`
-	FullName string `json:"full_name"`
-	Outdated bool   `json:"outdated"`
-	Versions struct {
+	provider  compulsive.Provider
+	Name      string `json:"name"`
+	FullName  string `json:"full_name"`
+	Outdated  bool   `json:"outdated"`
+	LinkedKeg string `json:"linked_keg"`
+	Revision  int    `json:"revision"`
+	Versions  struct {
 		Stable string `json:"stable"`
 	} `json:"versions"`
 	Installed []struct {
@@ -23,7 +31,72 @@ type brewPkgInfo struct {
 	} `json:"installed"`
 }
 
-type Brew struct{}
+func brewPath(arg string) (string, error) {
+	out, err := exec.Command("brew", arg).Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// listKegBinaries gives the executables a keg links into the brew prefix.
+func listKegBinaries(keg string) []string {
+	var binaries []string
+	for _, dir := range []string{"bin", "sbin"} {
+		entries, _ := ioutil.ReadDir(filepath.Join(keg, dir))
+		for _, it := range entries {
+			if !it.IsDir() {
+				binaries = append(binaries, it.Name())
+			}
+		}
+	}
+	return binaries
+}
+
+const bottleConcurrency = 4
+
+var ghcrURL = "https://ghcr.io/v2/homebrew/core/"
+
+type bottleIndexPayload struct {
+	Annotations struct {
+		Created time.Time `json:"org.opencontainers.image.created"`
+	} `json:"annotations"`
+}
+
+// fetchBottleDate gives the creation date of the bottles of a homebrew/core
+// formula version, as published in the GitHub packages registry.
+func fetchBottleDate(name, version string, revision int) (time.Time, error) {
+	repo := strings.NewReplacer("@", "/", "+", "x").Replace(name)
+	tag := version
+	if revision > 0 {
+		tag += "_" + strconv.Itoa(revision)
+	}
+	req, err := newRequest(ghcrURL + repo + "/manifests/" + tag)
+	if err != nil {
+		return time.Time{}, err
+	}
+	// anonymous token accepted by the registry for public packages
+	req.Header.Set("Authorization", "Bearer QQ==")
+	req.Header.Set("Accept", "application/vnd.oci.image.index.v1+json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return time.Time{}, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	var payload bottleIndexPayload
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return time.Time{}, err
+	}
+	return payload.Annotations.Created, nil
+}
+
+type Brew struct {
+	prefix string
+	cellar string
+}
 
 func (p *Brew) Name() string {
 	return "brew"
@@ -43,7 +116,29 @@ func (p *Brew) Sync() error {
 	return exec.Command("brew", "update").Run()
 }
 
+func (p *Brew) BinaryDirs() []string {
+	if p.prefix == "" {
+		p.prefix, _ = brewPath("--prefix")
+	}
+	if p.cellar == "" {
+		p.cellar, _ = brewPath("--cellar")
+	}
+	return []string{filepath.Join(p.prefix, "bin"), filepath.Join(p.prefix, "sbin"), p.cellar}
+}
+
 func (p *Brew) List() ([]compulsive.Package, error) {
+	if p.cellar == "" {
+		cellar, err := brewPath("--cellar")
+		if err != nil {
+			return nil, fmt.Errorf("could not locate the cellar: %s", err)
+		}
+		p.cellar = cellar
+	}
+	// brew refuses to run as root, a shared prefix (e.g. a system-wide
+	// Linuxbrew) is upgraded by its owner
+	if !isWritable(p.cellar) {
+		log.Printf("the brew prefix is not writable, upgrades must be run by the owner of %s", p.cellar)
+	}
 	out, err := exec.Command("brew", "info", "--json=v1", "--installed").Output()
 	if err != nil {
 		return nil, fmt.Errorf("error while fetching packages: %s", err)
@@ -53,24 +148,41 @@ func (p *Brew) List() ([]compulsive.Package, error) {
 		return nil, fmt.Errorf("failed to decode package info: %s", err)
 	}
 	var pkgs []compulsive.Package
-	for _, it := range pkgsInfo {
+	var outdated []int
+	for i, it := range pkgsInfo {
 		var versions []string
 		for _, installed := range it.Installed {
 			versions = append(versions, installed.Version)
 		}
+		linked := it.LinkedKeg
+		if linked == "" && len(versions) > 0 {
+			linked = versions[len(versions)-1]
+		}
 		pkg := compulsive.Package{
 			Provider:    p,
 			Name:        it.FullName,
 			Label:       it.Name,
+			Binaries:    listKegBinaries(filepath.Join(p.cellar, it.Name, linked)),
 			State:       compulsive.StateUpToDate,
 			Version:     strings.Join(versions, "/"),
 			NextVersion: it.Versions.Stable,
 		}
 		if it.Outdated {
 			pkg.State = compulsive.StateOutdated
+			if !strings.Contains(it.FullName, "/") {
+				outdated = append(outdated, i)
+			}
 		}
 		pkgs = append(pkgs, pkg)
 	}
+	fetchConcurrently(len(outdated), bottleConcurrency, func(i int) {
+		it := pkgsInfo[outdated[i]]
+		released, err := fetchBottleDate(it.Name, it.Versions.Stable, it.Revision)
+		if err != nil {
+			log.Printf("failed to fetch release date for package %q: %s", it.Name, err)
+		}
+		pkgs[outdated[i]].Released = released
+	})
 	return pkgs, nil
 }
 
@@ -0,0 +1,31 @@
+package providers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFetchBottleDate(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("User-Agent") != userAgent {
+			t.Errorf("missing user agent")
+		}
+		if r.URL.Path != "/python/3.12/manifests/3.12.4_1" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"annotations":{"org.opencontainers.image.created":"2024-06-07T10:00:00Z"}}`))
+	}))
+	defer srv.Close()
+	defer func(orig string) { ghcrURL = orig }(ghcrURL)
+	ghcrURL = srv.URL + "/"
+
+	date, err := fetchBottleDate("python@3.12", "3.12.4", 1)
+	if err != nil || date.Day() != 7 {
+		t.Errorf("got %s, %v", date, err)
+	}
+	if _, err := fetchBottleDate("python@3.12", "3.12.4", 0); err == nil {
+		t.Errorf("expected an error for a missing bottle")
+	}
+}
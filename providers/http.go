@@ -0,0 +1,42 @@
+package providers
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+const userAgent = "compulsive (https://github.com/casimir/compulsive)"
+
+// httpClient is shared by the registry lookups, a stalled server failing
+// the lookup instead of blocking the listing.
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// newRequest builds a GET request identifying compulsive to the server.
+func newRequest(url string) (*http.Request, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	return req, nil
+}
+
+// fetchConcurrently calls fetch for every index below n, with at most limit
+// calls running at the same time.
+func fetchConcurrently(n, limit int, fetch func(i int)) {
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			fetch(i)
+		}(i)
+	}
+	wg.Wait()
+}
@@ -11,10 +11,13 @@ func initInstances() map[string]compulsive.Provider {
 		NewGo(),
 		NewBrew(),
 		NewCargo(),
-		NewPip(""),
-		NewPip("2"),
-		NewPip("3"),
+		NewRustup(),
 	}
+	instances = append(instances, pipProviders()...)
+	return newInstanceMap(instances)
+}
+
+func newInstanceMap(instances []compulsive.Provider) map[string]compulsive.Provider {
 	instanceMap := make(map[string]compulsive.Provider, len(instances))
 	for _, it := range instances {
 		instanceMap[it.Name()] = it
@@ -22,10 +25,19 @@ func initInstances() map[string]compulsive.Provider {
 	return instanceMap
 }
 
-var Instances = initInstances()
+// Instances are the providers by name, discovered on first use as it
+// involves probing the installed interpreters.
+var Instances map[string]compulsive.Provider
+
+func instances() map[string]compulsive.Provider {
+	if Instances == nil {
+		Instances = initInstances()
+	}
+	return Instances
+}
 
 func Check(name string) error {
-	pvd, ok := Instances[name]
+	pvd, ok := instances()[name]
 	if !ok {
 		return compulsive.ErrProviderNotFound
 	}
@@ -37,7 +49,7 @@ func Check(name string) error {
 
 func list(filterFunc func(compulsive.Provider) bool) []compulsive.Provider {
 	var pvds []compulsive.Provider
-	for _, pvd := range Instances {
+	for _, pvd := range instances() {
 		if filterFunc == nil || filterFunc(pvd) {
 			pvds = append(pvds, pvd)
 		}
@@ -1,29 +1,55 @@
 package providers
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"os/exec"
-	"regexp"
+	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/casimir/compulsive"
 )
 
-var (
-	defaultChecked    = false
-	defaultPythonRoot = ""
-)
+func normalizePipName(name string) string {
+	return strings.ToLower(strings.NewReplacer("_", "-", ".", "-").Replace(name))
+}
 
-var pipRe = regexp.MustCompile(`pip (?P<version>\d+.\d+.\d+) from (?P<root>.+) \((?P<pyversion>.+)\)`)
+func unmarshalEntryPoints(raw []byte) []string {
+	var scripts []string
+	inScripts := false
+	for _, line := range bytes.Split(raw, []byte("\n")) {
+		text := strings.TrimSpace(string(line))
+		if strings.HasPrefix(text, "[") {
+			inScripts = text == "[console_scripts]" || text == "[gui_scripts]"
+			continue
+		}
+		if i := strings.Index(text, "="); inScripts && i > 0 {
+			scripts = append(scripts, strings.TrimSpace(text[:i]))
+		}
+	}
+	return scripts
+}
 
-func checkVersion(version string) (bool, string) {
-	out, err := exec.Command("pip"+version, "--version").Output()
-	if err != nil {
-		return false, ""
+// loadEntryPoints gives the scripts installed by each distribution of a
+// site-packages directory, indexed by normalized distribution name.
+func loadEntryPoints(sitePackages string) map[string][]string {
+	scripts := make(map[string][]string)
+	for _, pattern := range []string{"*.dist-info", "*.egg-info"} {
+		dirs, _ := filepath.Glob(filepath.Join(sitePackages, pattern))
+		for _, dir := range dirs {
+			raw, err := ioutil.ReadFile(filepath.Join(dir, "entry_points.txt"))
+			if err != nil {
+				continue
+			}
+			base := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(dir), ".dist-info"), ".egg-info")
+			name := normalizePipName(strings.SplitN(base, "-", 2)[0])
+			scripts[name] = append(scripts[name], unmarshalEntryPoints(raw)...)
+		}
 	}
-	matches := pipRe.FindSubmatch(out)
-	return true, string(matches[2])
+	return scripts
 }
 
 type pipPkgInfo struct {
@@ -34,36 +60,111 @@ type pipPkgInfo struct {
 }
 
 type Pip struct {
-	version  string
-	bin      string
+	name     string
+	python   pythonInterpreter
 	packages []pipPkgInfo
 }
 
 func (p *Pip) Name() string {
-	return p.bin
+	return p.name
+}
+
+func (p *Pip) Ecosystem() string {
+	return "PyPI"
 }
 
 func (p *Pip) IsAvailable() bool {
-	if !defaultChecked {
-		defaultChecked, defaultPythonRoot = checkVersion("")
+	return p.python.Path != "" && fileExists(p.python.Path)
+}
+
+var (
+	brewPrefixOnce sync.Once
+	brewPrefix     string
+)
+
+// homebrewPrefix gives the brew prefix, empty when brew is not installed.
+func homebrewPrefix() string {
+	brewPrefixOnce.Do(func() {
+		brewPrefix, _ = brewPath("--prefix")
+	})
+	return brewPrefix
+}
+
+// Dependencies declares brew when the interpreter is one of its formulae, a
+// virtual environment following the interpreter it was created from.
+func (p *Pip) Dependencies() []string {
+	prefix := p.python.BasePrefix
+	if prefix == "" {
+		prefix = p.python.Prefix
 	}
-	if p.version == "" {
-		return defaultChecked && defaultPythonRoot != ""
+	brew := homebrewPrefix()
+	if brew == "" || prefix == "" {
+		return nil
 	}
-	available, pythonRoot := checkVersion(p.version)
-	if defaultChecked {
-		return available && pythonRoot != defaultPythonRoot
+	if rel, err := filepath.Rel(brew, prefix); err != nil || strings.HasPrefix(rel, "..") {
+		return nil
 	}
-	return available
+	return []string{"brew"}
+}
+
+// NeedsRoot tells whether updates write to a site-packages owned by another
+// user. pip falls back to the user site-packages when it is enabled, and
+// installations managed by the system package manager are never escalated.
+func (p *Pip) NeedsRoot(op compulsive.Operation) bool {
+	if op != compulsive.OpUpdate || p.python.UserSite != "" || p.python.Managed {
+		return false
+	}
+	return !isWritable(p.python.Purelib)
+}
 
+func (p *Pip) LockKey() string {
+	return "pip:" + p.python.Purelib
+}
+
+func (p *Pip) BootstrapPackage() string {
+	return "pip"
+}
+
+// pip gives the command running the pip of the interpreter.
+func (p *Pip) pip() string {
+	return p.python.Path + " -m pip"
+}
+
+func (p *Pip) pipCommand(args ...string) *exec.Cmd {
+	return exec.Command(p.python.Path, append([]string{"-m", "pip"}, args...)...)
 }
 
 func (p *Pip) Sync() error {
-	return exec.Command(p.bin, "install", "--upgrade", "pip").Run()
+	return p.pipCommand("install", "--upgrade", "pip").Run()
+}
+
+// BinaryDirs gives the scripts directory of the interpreter, followed by
+// the one of the user site-packages when it exists.
+func (p *Pip) BinaryDirs() []string {
+	dirs := []string{p.python.Scripts}
+	if dir := filepath.Dir(p.python.Path); dir != p.python.Scripts {
+		dirs = append(dirs, dir)
+	}
+	if dir := p.python.UserScripts; dir != "" && dir != p.python.Scripts && fileExists(dir) {
+		dirs = append(dirs, dir)
+	}
+	return dirs
+}
+
+// entryPoints gives the scripts of the installed distributions, the ones
+// of the user site-packages shadowing the others as they do on sys.path.
+func (p *Pip) entryPoints() map[string][]string {
+	scripts := loadEntryPoints(p.python.Purelib)
+	if p.python.UserSite != "" && fileExists(p.python.UserSite) {
+		for name, it := range loadEntryPoints(p.python.UserSite) {
+			scripts[name] = it
+		}
+	}
+	return scripts
 }
 
 func (p *Pip) List() ([]compulsive.Package, error) {
-	outOutdated, err := exec.Command(p.bin, "list", "--format", "json", "--outdated").Output()
+	outOutdated, err := p.pipCommand("list", "--format", "json", "--outdated").Output()
 	if err != nil {
 		return nil, fmt.Errorf("error while fetching packages: %s", err)
 	}
@@ -75,7 +176,7 @@ func (p *Pip) List() ([]compulsive.Package, error) {
 	for _, it := range pkgsOutdated {
 		outdatedMap[it.Name] = it
 	}
-	outAll, err := exec.Command(p.bin, "list", "--format", "json").Output()
+	outAll, err := p.pipCommand("list", "--format", "json").Output()
 	if err != nil {
 		return nil, fmt.Errorf("error while fetching packages: %s", err)
 	}
@@ -83,12 +184,14 @@ func (p *Pip) List() ([]compulsive.Package, error) {
 	if err := json.Unmarshal(outAll, &pkgsAll); err != nil {
 		return nil, fmt.Errorf("failed to decode package info: %s", err)
 	}
+	scripts := p.entryPoints()
 	var pkgs []compulsive.Package
 	for _, it := range pkgsAll {
 		pkg := compulsive.Package{
 			Provider: p,
 			Name:     it.Name,
 			Label:    it.Name,
+			Binaries: scripts[normalizePipName(it.Name)],
 			Version:  it.Version,
 			State:    compulsive.StateUpToDate,
 		}
@@ -98,17 +201,40 @@ func (p *Pip) List() ([]compulsive.Package, error) {
 		}
 		pkgs = append(pkgs, pkg)
 	}
+	var outdated []*compulsive.Package
+	var currents []string
+	for i := range pkgs {
+		if pkgs[i].State == compulsive.StateOutdated {
+			outdated = append(outdated, &pkgs[i])
+			currents = append(currents, pkgs[i].Version)
+		}
+	}
+	fetchPyPIInfos(outdated, currents)
+	for _, it := range outdated {
+		if compulsive.CompareVersions(it.Version, it.NextVersion) >= 0 {
+			it.NextVersion = outdatedMap[it.Name].LatestVersion
+		}
+	}
 	return pkgs, nil
 }
 
 func (p *Pip) UpdateCommand(pkgs ...compulsive.Package) string {
 	var names []string
 	for _, it := range pkgs {
-		names = append(names, it.Name)
+		if MinReleaseAge > 0 && it.NextVersion != "" {
+			// the latest version may still be cooling down
+			names = append(names, it.Name+"=="+it.NextVersion)
+		} else {
+			names = append(names, it.Name)
+		}
 	}
-	return p.bin + " install --upgrade " + strings.Join(names, " ")
+	return p.pip() + " install --upgrade " + strings.Join(names, " ")
+}
+
+func (p *Pip) InstallVersionCommand(pkg compulsive.Package, version string) string {
+	return p.pip() + " install " + pkg.Name + "==" + version
 }
 
-func NewPip(version string) compulsive.Provider {
-	return &Pip{version: version, bin: "pip" + version}
+func NewPip(name string, python pythonInterpreter) compulsive.Provider {
+	return &Pip{name: name, python: python}
 }
@@ -0,0 +1,231 @@
+package providers
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/casimir/compulsive"
+)
+
+var requirementRe = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9._-]*)\s*(\[[^\]]*\])?\s*([^;]*)`)
+
+type pipRequirement struct {
+	name string
+	spec string
+}
+
+func (r pipRequirement) pinned() string {
+	if strings.HasPrefix(r.spec, "==") && !strings.Contains(r.spec, ",") {
+		return strings.TrimSpace(r.spec[2:])
+	}
+	return ""
+}
+
+func parseRequirement(line string) (pipRequirement, bool) {
+	if i := strings.Index(line, " #"); i >= 0 {
+		line = line[:i]
+	}
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "-") || strings.Contains(line, "://") {
+		return pipRequirement{}, false
+	}
+	matches := requirementRe.FindStringSubmatch(line)
+	if matches == nil {
+		return pipRequirement{}, false
+	}
+	return pipRequirement{
+		name: matches[1],
+		spec: strings.Join(strings.Fields(matches[3]), ""),
+	}, true
+}
+
+func unmarshalRequirements(raw []byte) []pipRequirement {
+	var reqs []pipRequirement
+	for _, line := range bytes.Split(raw, []byte("\n")) {
+		if req, ok := parseRequirement(string(line)); ok {
+			reqs = append(reqs, req)
+		}
+	}
+	return reqs
+}
+
+// unmarshalPyproject extracts the requirements listed in the
+// project.dependencies array of a pyproject.toml.
+func unmarshalPyproject(raw []byte) []pipRequirement {
+	var reqs []pipRequirement
+	section, inArray := "", false
+	for _, line := range bytes.Split(raw, []byte("\n")) {
+		text := strings.TrimSpace(string(line))
+		if !inArray {
+			if matches := tomlSectionRe.FindStringSubmatch(text); matches != nil {
+				section = matches[1]
+				continue
+			}
+			if section != "project" || !strings.HasPrefix(text, "dependencies") {
+				continue
+			}
+			i := strings.Index(text, "[")
+			if i < 0 {
+				continue
+			}
+			text, inArray = text[i+1:], true
+		}
+		for _, it := range tomlStringRe.FindAllStringSubmatch(text, -1) {
+			if req, ok := parseRequirement(it[1]); ok {
+				reqs = append(reqs, req)
+			}
+		}
+		if strings.Contains(tomlStringRe.ReplaceAllString(text, ""), "]") {
+			inArray = false
+		}
+	}
+	return reqs
+}
+
+type PipProject struct {
+	root string
+}
+
+func (p *PipProject) Name() string {
+	return "pip"
+}
+
+func (p *PipProject) manifests() []string {
+	manifests, _ := filepath.Glob(filepath.Join(p.root, "requirements*.txt"))
+	sort.Strings(manifests)
+	if pyproject := filepath.Join(p.root, "pyproject.toml"); fileExists(pyproject) {
+		manifests = append(manifests, pyproject)
+	}
+	return manifests
+}
+
+// python gives the interpreter of the project virtualenv, empty if there is
+// none. Dependencies are never installed outside of it.
+func (p *PipProject) python() string {
+	binDir, name := "bin", "python"
+	if runtime.GOOS == "windows" {
+		binDir, name = "Scripts", "python.exe"
+	}
+	for _, it := range []string{".venv", "venv"} {
+		if bin := filepath.Join(p.root, it, binDir, name); fileExists(bin) {
+			return bin
+		}
+	}
+	return ""
+}
+
+func (p *PipProject) Ecosystem() string {
+	return "PyPI"
+}
+
+func (p *PipProject) IsAvailable() bool {
+	return len(p.manifests()) > 0
+}
+
+func (p *PipProject) Sync() error {
+	return nil
+}
+
+func (p *PipProject) List() ([]compulsive.Package, error) {
+	seen := make(map[string]bool)
+	var pkgs []compulsive.Package
+	var pinneds []string
+	for _, manifest := range p.manifests() {
+		raw, err := ioutil.ReadFile(manifest)
+		if err != nil {
+			return nil, fmt.Errorf("could not read %s: %s", manifest, err)
+		}
+		var reqs []pipRequirement
+		if filepath.Base(manifest) == "pyproject.toml" {
+			reqs = unmarshalPyproject(raw)
+		} else {
+			reqs = unmarshalRequirements(raw)
+		}
+		for _, it := range reqs {
+			key := strings.ToLower(it.name)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			pkg := compulsive.Package{
+				Provider: p,
+				Name:     it.name,
+				Label:    it.name,
+				State:    compulsive.StateUnknown,
+				Version:  it.spec,
+			}
+			if pinned := it.pinned(); pinned != "" {
+				pkg.Version = pinned
+			}
+			pkgs = append(pkgs, pkg)
+			pinneds = append(pinneds, it.pinned())
+		}
+	}
+	refs := make([]*compulsive.Package, len(pkgs))
+	for i := range pkgs {
+		refs[i] = &pkgs[i]
+	}
+	fetchPyPIInfos(refs, pinneds)
+	for i, it := range pinneds {
+		// the next version is only known once fetched
+		if it == "" || pkgs[i].NextVersion == "" {
+			continue
+		}
+		if compulsive.CompareVersions(it, pkgs[i].NextVersion) < 0 {
+			pkgs[i].State = compulsive.StateOutdated
+		} else {
+			pkgs[i].State = compulsive.StateUpToDate
+		}
+	}
+	return pkgs, nil
+}
+
+// pinScript rewrites the exact pins of the manifests given as arguments,
+// pins being given as name==version arguments. It holds no double quote to
+// be passed as a single argument to both sh and cmd.
+var pinScript = strings.Join([]string{
+	"import functools,re,sys",
+	"a=sys.argv[1:]",
+	"p=[x.split('==',1) for x in a if '==' in x]",
+	`s=lambda t,nv:re.sub(r'(?im)((?:^|[\x22\x27])\s*'+re.escape(nv[0])+r'\s*(?:\[[^\]]*\])?\s*==\s*)[^\s\x22\x27,;#]+(?=\s*(?:$|[;#\x22\x27]))',lambda m:m.group(1)+nv[1],t)`,
+	"r=lambda f:open(f,newline='').read()",
+	"w=lambda f,t:open(f,'w',newline='').write(t)",
+	"[w(f,u) for f in a if '==' not in f for t in [r(f)] for u in [functools.reduce(s,p,t)] if u!=t]",
+}, ";")
+
+// UpdateCommand installs the packages in the project virtualenv and updates
+// their pins in the manifests. There is no command without a virtualenv.
+func (p *PipProject) UpdateCommand(pkgs ...compulsive.Package) string {
+	python := p.python()
+	if python == "" {
+		return ""
+	}
+	var specs, pins []string
+	for _, it := range pkgs {
+		spec := it.Name
+		if it.NextVersion != "" {
+			spec += "==" + it.NextVersion
+			pins = append(pins, shellQuote(spec))
+		}
+		specs = append(specs, shellQuote(spec))
+	}
+	commands := []string{shellQuote(python) + " -m pip install --upgrade " + strings.Join(specs, " ")}
+	if len(pins) > 0 {
+		var manifests []string
+		for _, it := range p.manifests() {
+			manifests = append(manifests, shellQuote(it))
+		}
+		commands = append(commands, shellQuote(python)+" -c "+shellQuote(pinScript)+" "+strings.Join(manifests, " ")+" "+strings.Join(pins, " "))
+	}
+	return strings.Join(commands, "\n")
+}
+
+func NewPipProject(root string) compulsive.Provider {
+	return &PipProject{root: root}
+}
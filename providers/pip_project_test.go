@@ -0,0 +1,97 @@
+package providers
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/casimir/compulsive"
+)
+
+func TestUnmarshalRequirements(t *testing.T) {
+	content := []byte(`# deps
+requests==2.31.0
+Django >= 4.2, < 5  # web
+uvicorn[standard]==0.23.2 ; python_version >= "3.8"
+-r requirements-dev.txt
+git+https://github.com/psf/black
+`)
+	expected := []pipRequirement{
+		{name: "requests", spec: "==2.31.0"},
+		{name: "Django", spec: ">=4.2,<5"},
+		{name: "uvicorn", spec: "==0.23.2"},
+	}
+	got := unmarshalRequirements(content)
+	if !reflect.DeepEqual(expected, got) {
+		t.Fail()
+	}
+}
+
+func TestUnmarshalPyproject(t *testing.T) {
+	content := []byte(`[project]
+name = "demo"
+dependencies = [
+    "httpx>=0.24",
+    "rich[jupyter]==13.5.2",
+]
+
+[project.optional-dependencies]
+test = ["pytest"]
+`)
+	expected := []pipRequirement{
+		{name: "httpx", spec: ">=0.24"},
+		{name: "rich", spec: "==13.5.2"},
+	}
+	got := unmarshalPyproject(content)
+	if !reflect.DeepEqual(expected, got) {
+		t.Fail()
+	}
+}
+
+func TestPipProjectUpdateCommand(t *testing.T) {
+	python, err := exec.LookPath("python3")
+	if err != nil || runtime.GOOS == "windows" {
+		t.Skip("no python3 to run the pin update")
+	}
+	root := filepath.Join(t.TempDir(), "my project")
+	p := &PipProject{root: root}
+	requests := compulsive.Package{Provider: p, Name: "requests", Version: "2.31.0", NextVersion: "2.32.3"}
+	if got := p.UpdateCommand(requests); got != "" {
+		t.Errorf("got a command without virtualenv: %q", got)
+	}
+
+	writeFile(t, filepath.Join(root, "requirements.txt"), "requests==2.31.0  # http\nrequests-toolbelt==1.0.0\nDjango>=4.2\n")
+	writeFile(t, filepath.Join(root, "pyproject.toml"), "[project]\ndependencies = [\n    \"Requests[socks] == 2.31.0\",\n    \"rich==13.5.2\",\n]\n")
+	if err := os.MkdirAll(filepath.Join(root, ".venv", "bin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	venvPython := filepath.Join(root, ".venv", "bin", "python")
+	if err := os.Symlink(python, venvPython); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(p.UpdateCommand(requests), "\n")
+	if expected := "'" + venvPython + "' -m pip install --upgrade requests==2.32.3"; len(lines) != 2 || lines[0] != expected {
+		t.Fatalf("got %q", lines)
+	}
+	if out, err := exec.Command("sh", "-c", lines[1]).CombinedOutput(); err != nil {
+		t.Fatalf("pin update failed: %s\n%s", err, out)
+	}
+	expected := map[string]string{
+		"requirements.txt": "requests==2.32.3  # http\nrequests-toolbelt==1.0.0\nDjango>=4.2\n",
+		"pyproject.toml":   "[project]\ndependencies = [\n    \"Requests[socks] == 2.32.3\",\n    \"rich==13.5.2\",\n]\n",
+	}
+	for name, content := range expected {
+		raw, err := ioutil.ReadFile(filepath.Join(root, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(raw) != content {
+			t.Errorf("%s: got %q", name, raw)
+		}
+	}
+}
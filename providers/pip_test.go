@@ -0,0 +1,177 @@
+package providers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/casimir/compulsive"
+)
+
+func TestUnmarshalEntryPoints(t *testing.T) {
+	content := []byte(`[console_scripts]
+black = black:patched_main
+blackd = blackd:patched_main [d]
+
+[distutils.commands]
+foo = bar:baz
+
+[gui_scripts]
+idle-black = black.gui:main
+`)
+	expected := []string{"black", "blackd", "idle-black"}
+	got := unmarshalEntryPoints(content)
+	if !reflect.DeepEqual(expected, got) {
+		t.Fail()
+	}
+}
+
+func TestPythonNameRe(t *testing.T) {
+	for name, expected := range map[string]bool{
+		"python":         true,
+		"python3":        true,
+		"python3.12":     true,
+		"python.exe":     true,
+		"python3-config": false,
+		"python3.12m":    false,
+	} {
+		if got := pythonNameRe.MatchString(name); got != expected {
+			t.Errorf("pythonNameRe.MatchString(%q) = %v", name, got)
+		}
+	}
+	if got := (pythonInterpreter{Version: "3.12.1"}).shortVersion(); got != "3.12" {
+		t.Errorf("got %q", got)
+	}
+}
+
+// writeFakePython writes an interpreter answering the probe with the given
+// site-packages directory.
+func writeFakePython(t *testing.T, dir, purelib, pip string) string {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "python3")
+	script := fmt.Sprintf(`#!/bin/sh
+echo '{"version": "3.12.1", "prefix": "%s", "purelib": "%s", "scripts": "%s", "pip": "%s"}'
+`, dir, purelib, dir, pip)
+	if err := ioutil.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDiscoverPythons(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake interpreters are shell scripts")
+	}
+	root := t.TempDir()
+	first := writeFakePython(t, filepath.Join(root, "a"), "/site/shared", "24.0")
+	link := filepath.Join(root, "link")
+	if err := os.Symlink(first, link); err != nil {
+		t.Fatal(err)
+	}
+	samePurelib := writeFakePython(t, filepath.Join(root, "b"), "/site/shared", "24.0")
+	noPip := writeFakePython(t, filepath.Join(root, "c"), "/site/c", "")
+	other := writeFakePython(t, filepath.Join(root, "d"), "/site/d", "23.3")
+
+	var got []string
+	for _, it := range discoverPythons([]string{first, link, samePurelib, noPip, other}) {
+		got = append(got, it.Path)
+	}
+	if expected := []string{first, other}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestPipNames(t *testing.T) {
+	interpreters := []pythonInterpreter{
+		{Version: "3.12.1", Prefix: "/home/me/project/.venv", BasePrefix: "/usr"},
+		{Version: "3.12.1", Prefix: "/usr", BasePrefix: "/usr"},
+		{Version: "3.12.4", Prefix: "/opt/homebrew/opt/python@3.12", BasePrefix: "/opt/homebrew/opt/python@3.12"},
+		{Version: "3.11.7", Prefix: "/home/me/.pyenv/versions/3.11.7", BasePrefix: "/home/me/.pyenv/versions/3.11.7"},
+		{Version: "3.12.1", Prefix: "/home/me/project/.venv", BasePrefix: "/usr"},
+	}
+	expected := []string{"pip@3.12-project", "pip@3.12", "pip@3.12-python-3.12", "pip@3.11", "pip@3.12-project-2"}
+	if got := pipNames(interpreters); !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestPipNeedsRoot(t *testing.T) {
+	readOnly := t.TempDir()
+	if err := os.Chmod(readOnly, 0555); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(readOnly, 0755)
+	if isWritable(readOnly) {
+		t.Skip("running as root")
+	}
+	cases := []struct {
+		python   pythonInterpreter
+		expected bool
+	}{
+		{pythonInterpreter{Purelib: readOnly}, true},
+		{pythonInterpreter{Purelib: readOnly, UserSite: "/home/me/.local/lib/python3.12/site-packages"}, false},
+		{pythonInterpreter{Purelib: readOnly, Managed: true}, false},
+		{pythonInterpreter{Purelib: t.TempDir()}, false},
+	}
+	for _, it := range cases {
+		pvd := &Pip{python: it.python}
+		if got := pvd.NeedsRoot(compulsive.OpUpdate); got != it.expected {
+			t.Errorf("NeedsRoot(%+v) = %v", it.python, got)
+		}
+	}
+}
+
+func TestPipDependencies(t *testing.T) {
+	brewPrefixOnce.Do(func() {})
+	defer func(orig string) { brewPrefix = orig }(brewPrefix)
+	brewPrefix = "/opt/homebrew"
+	cases := []struct {
+		python   pythonInterpreter
+		expected []string
+	}{
+		{pythonInterpreter{Prefix: "/opt/homebrew/opt/python@3.12/Frameworks/Python.framework/Versions/3.12"}, []string{"brew"}},
+		{pythonInterpreter{Prefix: "/home/me/venv", BasePrefix: "/opt/homebrew/Cellar/python@3.12/3.12.4"}, []string{"brew"}},
+		{pythonInterpreter{Prefix: "/usr", BasePrefix: "/usr"}, nil},
+		{pythonInterpreter{Prefix: "/opt/homebrew-old/python"}, nil},
+	}
+	for _, it := range cases {
+		p := &Pip{python: it.python}
+		if got := p.Dependencies(); !reflect.DeepEqual(got, it.expected) {
+			t.Errorf("%s: got %v", it.python.Prefix, got)
+		}
+	}
+}
+
+func TestPipUserSite(t *testing.T) {
+	root := t.TempDir()
+	purelib, userSite, userScripts := filepath.Join(root, "site"), filepath.Join(root, "user", "site"), filepath.Join(root, "user", "bin")
+	writeFile(t, filepath.Join(purelib, "black-24.1.0.dist-info", "entry_points.txt"), "[console_scripts]\nblack = black:main\n")
+	writeFile(t, filepath.Join(userSite, "httpie-3.2.2.dist-info", "entry_points.txt"), "[console_scripts]\nhttp = httpie.__main__:main\n")
+	if err := os.MkdirAll(userScripts, 0755); err != nil {
+		t.Fatal(err)
+	}
+	p := &Pip{python: pythonInterpreter{
+		Path:        filepath.Join(root, "bin", "python3"),
+		Purelib:     purelib,
+		Scripts:     filepath.Join(root, "bin"),
+		UserSite:    userSite,
+		UserScripts: userScripts,
+	}}
+	if got := p.BinaryDirs(); !reflect.DeepEqual(got, []string{filepath.Join(root, "bin"), userScripts}) {
+		t.Errorf("got %v", got)
+	}
+	expected := map[string][]string{"black": {"black"}, "httpie": {"http"}}
+	if got := p.entryPoints(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %v", got)
+	}
+	p.python.UserScripts = filepath.Join(root, "missing")
+	if got := p.BinaryDirs(); len(got) != 1 {
+		t.Errorf("missing user scripts directory listed: %v", got)
+	}
+}
@@ -0,0 +1,53 @@
+package providers
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/casimir/compulsive"
+)
+
+var projectMarkers = []string{"go.mod", "Cargo.toml", "pyproject.toml", "requirements*.txt", ".git"}
+
+func isProjectRoot(dir string) bool {
+	for _, it := range projectMarkers {
+		if matches, _ := filepath.Glob(filepath.Join(dir, it)); len(matches) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// FindProjectRoot walks up from dir until it finds a directory holding a
+// dependency manifest or a repository root.
+func FindProjectRoot(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		if isProjectRoot(dir) {
+			return dir, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", compulsive.ErrProjectNotFound
+		}
+		dir = parent
+	}
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// UseProject replaces the global providers with the ones handling the
+// dependencies of the project located at root.
+func UseProject(root string) {
+	Instances = newInstanceMap([]compulsive.Provider{
+		NewGoProject(root),
+		NewCargoProject(root),
+		NewPipProject(root),
+	})
+}
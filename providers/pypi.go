@@ -0,0 +1,82 @@
+package providers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/casimir/compulsive"
+)
+
+const pypiConcurrency = 4
+
+var pypiURL = "https://pypi.org/pypi/"
+
+type pypiPkgPayload struct {
+	Info struct {
+		Summary string `json:"summary"`
+		Version string `json:"version"`
+	} `json:"info"`
+	Releases map[string][]struct {
+		UploadTime time.Time `json:"upload_time_iso_8601"`
+		Yanked     bool      `json:"yanked"`
+	} `json:"releases"`
+}
+
+func (p pypiPkgPayload) releases() []release {
+	var releases []release
+	for version, files := range p.Releases {
+		if len(files) == 0 {
+			continue
+		}
+		rel := release{version: version, date: files[0].UploadTime, yanked: true}
+		for _, it := range files {
+			if it.UploadTime.Before(rel.date) {
+				rel.date = it.UploadTime
+			}
+			rel.yanked = rel.yanked && it.Yanked
+		}
+		releases = append(releases, rel)
+	}
+	return releases
+}
+
+// fetchPyPIInfo fills the summary, next version and its release date of a
+// package from PyPI, current being the version installed if known.
+func fetchPyPIInfo(pkg *compulsive.Package, current string) error {
+	req, err := newRequest(pypiURL + pkg.Name + "/json")
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	var payload pypiPkgPayload
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return err
+	}
+	pkg.Summary = payload.Info.Summary
+	pkg.NextVersion = payload.Info.Version
+	if rel, ok := pickRelease(payload.releases(), payload.Info.Version, current, time.Now()); ok {
+		pkg.NextVersion = rel.version
+		pkg.Released = rel.date
+	}
+	return nil
+}
+
+// fetchPyPIInfos fetches the data of several packages concurrently, currents
+// giving the installed version of each.
+func fetchPyPIInfos(pkgs []*compulsive.Package, currents []string) {
+	fetchConcurrently(len(pkgs), pypiConcurrency, func(i int) {
+		if err := fetchPyPIInfo(pkgs[i], currents[i]); err != nil {
+			log.Printf("failed to fetch data for package %q: %s", pkgs[i].Name, err)
+		}
+	})
+}
@@ -0,0 +1,58 @@
+package providers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/casimir/compulsive"
+)
+
+func TestFetchPyPIInfos(t *testing.T) {
+	var mu sync.Mutex
+	running, peak := 0, 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		running++
+		if running > peak {
+			peak = running
+		}
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			running--
+			mu.Unlock()
+		}()
+		if r.Header.Get("User-Agent") != userAgent {
+			t.Errorf("missing user agent")
+		}
+		if r.URL.Path == "/missing/json" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"info":{"summary":"A tool.","version":"2.0"}}`))
+	}))
+	defer srv.Close()
+	defer func(orig string) { pypiURL = orig }(pypiURL)
+	pypiURL = srv.URL + "/"
+
+	var pkgs []*compulsive.Package
+	var currents []string
+	for _, name := range []string{"a", "b", "c", "d", "e", "f", "missing"} {
+		pkgs = append(pkgs, &compulsive.Package{Name: name})
+		currents = append(currents, "1.0")
+	}
+	fetchPyPIInfos(pkgs, currents)
+	for _, it := range pkgs[:6] {
+		if it.Summary != "A tool." || it.NextVersion != "2.0" {
+			t.Errorf("%s: got %q %q", it.Name, it.Summary, it.NextVersion)
+		}
+	}
+	if pkgs[6].NextVersion != "" {
+		t.Errorf("missing package got a version: %q", pkgs[6].NextVersion)
+	}
+	if peak > pypiConcurrency {
+		t.Errorf("%d requests in flight, expected at most %d", peak, pypiConcurrency)
+	}
+}
@@ -0,0 +1,237 @@
+package providers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/casimir/compulsive"
+)
+
+var pythonNameRe = regexp.MustCompile(`^python(\d+(\.\d+)?)?(\.exe)?$`)
+
+// pythonProbe prints what compulsive needs to know about an interpreter,
+// with a syntax understood by both Python 2 and 3.
+const pythonProbe = `import json, os, site, sys, sysconfig
+try:
+    import pip
+    pipVersion = pip.__version__
+except Exception:
+    pipVersion = ""
+paths = sysconfig.get_paths()
+userSite, userScripts = "", ""
+if getattr(site, "ENABLE_USER_SITE", False):
+    userSite = site.getusersitepackages()
+    try:
+        scheme = sysconfig.get_preferred_scheme("user")
+    except AttributeError:
+        scheme = os.name + "_user"
+        if sys.platform == "darwin" and getattr(sys, "_framework", ""):
+            scheme = "osx_framework_user"
+    userScripts = sysconfig.get_path("scripts", scheme)
+print(json.dumps({
+    "version": "%d.%d.%d" % sys.version_info[:3],
+    "prefix": sys.prefix,
+    "base_prefix": getattr(sys, "base_prefix", getattr(sys, "real_prefix", sys.prefix)),
+    "purelib": paths["purelib"],
+    "scripts": paths["scripts"],
+    "usersite": userSite,
+    "userscripts": userScripts,
+    "managed": os.path.exists(os.path.join(paths["stdlib"], "EXTERNALLY-MANAGED")),
+    "pip": pipVersion,
+}))`
+
+// pythonInterpreter is a Python installation with pip available.
+type pythonInterpreter struct {
+	Path       string `json:"-"`
+	Version    string `json:"version"`
+	Prefix     string `json:"prefix"`
+	BasePrefix string `json:"base_prefix"`
+	Purelib    string `json:"purelib"`
+	Scripts    string `json:"scripts"`
+	// UserSite is where pip installs packages when Purelib is not writable,
+	// empty if user site-packages are disabled.
+	UserSite string `json:"usersite"`
+	// UserScripts is where pip installs the scripts of the packages of the
+	// user site-packages.
+	UserScripts string `json:"userscripts"`
+	// Managed tells whether the installation is managed by the system
+	// package manager (PEP 668), pip refusing to install into it.
+	Managed bool   `json:"managed"`
+	Pip     string `json:"pip"`
+}
+
+// isVenv tells whether the interpreter runs in a virtual environment.
+func (i pythonInterpreter) isVenv() bool {
+	return i.BasePrefix != "" && filepath.Clean(i.Prefix) != filepath.Clean(i.BasePrefix)
+}
+
+// label gives a short name for the prefix of the interpreter, virtual
+// environments being named after the directory holding them.
+func (i pythonInterpreter) label() string {
+	prefix := filepath.Clean(i.Prefix)
+	base := filepath.Base(prefix)
+	if strings.HasPrefix(base, ".") || base == "venv" || base == "env" {
+		base = filepath.Base(filepath.Dir(prefix))
+	}
+	return strings.Trim(strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '_' {
+			return r
+		}
+		return '-'
+	}, base), ".-")
+}
+
+// shortVersion gives the major and minor version, e.g. 3.12.
+func (i pythonInterpreter) shortVersion() string {
+	parts := strings.SplitN(i.Version, ".", 3)
+	if len(parts) < 2 {
+		return i.Version
+	}
+	return parts[0] + "." + parts[1]
+}
+
+// pythonCandidates gives the interpreters found in PATH, then in pyenv and
+// in the prefixes Pythons are commonly installed to.
+func pythonCandidates() []string {
+	dirs := filepath.SplitList(os.Getenv("PATH"))
+	pyenv := os.Getenv("PYENV_ROOT")
+	if pyenv == "" {
+		pyenv = expandHome("~/.pyenv")
+	}
+	patterns := []string{
+		filepath.Join(pyenv, "versions", "*", "bin"),
+		"/usr/bin",
+		"/usr/local/bin",
+		"/usr/local/opt/python@*/bin",
+		"/opt/homebrew/bin",
+		"/opt/homebrew/opt/python@*/bin",
+		"/opt/local/bin",
+		"/Library/Frameworks/Python.framework/Versions/*/bin",
+	}
+	if runtime.GOOS == "windows" {
+		patterns = []string{filepath.Join(os.Getenv("LOCALAPPDATA"), "Programs", "Python", "Python*")}
+	}
+	for _, it := range patterns {
+		matches, _ := filepath.Glob(it)
+		dirs = append(dirs, matches...)
+	}
+	// shims only dispatch to the versions scanned above, and slowly
+	shims := filepath.Join(pyenv, "shims")
+	var candidates []string
+	for _, dir := range dirs {
+		if filepath.Clean(dir) == shims {
+			continue
+		}
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, it := range entries {
+			if pythonNameRe.MatchString(it.Name()) {
+				candidates = append(candidates, filepath.Join(dir, it.Name()))
+			}
+		}
+	}
+	return candidates
+}
+
+func probePython(path string) (pythonInterpreter, bool) {
+	interpreter := pythonInterpreter{Path: path}
+	out, err := exec.Command(path, "-c", pythonProbe).Output()
+	if err != nil || json.Unmarshal(out, &interpreter) != nil {
+		return interpreter, false
+	}
+	return interpreter, interpreter.Pip != "" && interpreter.Purelib != ""
+}
+
+// discoverPythons gives the interpreters having pip among candidates, one
+// per site-packages directory, the first one found winning. Interpreters are
+// probed concurrently as there can be dozens of them.
+func discoverPythons(candidates []string) []pythonInterpreter {
+	var paths []string
+	seen := make(map[string]bool)
+	for _, it := range candidates {
+		resolved, err := filepath.EvalSymlinks(it)
+		if err != nil || seen[resolved] {
+			continue
+		}
+		seen[resolved] = true
+		paths = append(paths, it)
+	}
+	probed := make([]pythonInterpreter, len(paths))
+	valid := make([]bool, len(paths))
+	var wg sync.WaitGroup
+	for i, it := range paths {
+		wg.Add(1)
+		go func(i int, path string) {
+			defer wg.Done()
+			probed[i], valid[i] = probePython(path)
+		}(i, it)
+	}
+	wg.Wait()
+	var interpreters []pythonInterpreter
+	roots := make(map[string]bool)
+	for i, interpreter := range probed {
+		if !valid[i] {
+			continue
+		}
+		root := filepath.Clean(interpreter.Purelib)
+		if resolved, err := filepath.EvalSymlinks(root); err == nil {
+			root = resolved
+		}
+		if roots[root] {
+			continue
+		}
+		roots[root] = true
+		interpreters = append(interpreters, interpreter)
+	}
+	return interpreters
+}
+
+// pipNames names the providers of interpreters after their version, the
+// first installation of a version found outside of a virtual environment
+// getting the short name so that activating one does not rename providers.
+// Others are told apart by their prefix.
+func pipNames(interpreters []pythonInterpreter) []string {
+	order := make([]int, len(interpreters))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return !interpreters[order[a]].isVenv() && interpreters[order[b]].isVenv()
+	})
+	names := make([]string, len(interpreters))
+	taken := make(map[string]bool)
+	for _, i := range order {
+		it := interpreters[i]
+		name := "pip@" + it.shortVersion()
+		if taken[name] {
+			name += "-" + it.label()
+		}
+		for n := 2; taken[name]; n++ {
+			name = fmt.Sprintf("pip@%s-%s-%d", it.shortVersion(), it.label(), n)
+		}
+		taken[name] = true
+		names[i] = name
+	}
+	return names
+}
+
+// pipProviders creates a provider per interpreter found on the system.
+func pipProviders() []compulsive.Provider {
+	interpreters := discoverPythons(pythonCandidates())
+	var pvds []compulsive.Provider
+	for i, name := range pipNames(interpreters) {
+		pvds = append(pvds, NewPip(name, interpreters[i]))
+	}
+	return pvds
+}
@@ -0,0 +1,53 @@
+package providers
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/casimir/compulsive"
+)
+
+// MinReleaseAge is the age a release must reach before it is considered
+// available. Younger releases are ignored as long as an older candidate
+// exists, and reported without being proposed otherwise.
+var MinReleaseAge time.Duration
+
+type release struct {
+	version string
+	date    time.Time
+	yanked  bool
+}
+
+// prereleaseRe matches both semver and PEP 440 prereleases.
+var prereleaseRe = regexp.MustCompile(`(?i)(-|\d(a|b|c|rc|alpha|beta|pre)\d*$|\.?dev\d*$)`)
+
+func isPrerelease(version string) bool {
+	return prereleaseRe.MatchString(version)
+}
+
+// pickRelease gives the newest release old enough to be adopted if it is
+// newer than current, the newest release otherwise.
+func pickRelease(releases []release, latest, current string, now time.Time) (release, bool) {
+	var newest, eligible release
+	for _, it := range releases {
+		if it.yanked || compulsive.CompareVersions(it.version, latest) > 0 {
+			continue
+		}
+		if isPrerelease(it.version) && !isPrerelease(latest) {
+			continue
+		}
+		if newest.version == "" || compulsive.CompareVersions(it.version, newest.version) > 0 {
+			newest = it
+		}
+		if now.Sub(it.date) < MinReleaseAge {
+			continue
+		}
+		if eligible.version == "" || compulsive.CompareVersions(it.version, eligible.version) > 0 {
+			eligible = it
+		}
+	}
+	if eligible.version != "" && compulsive.CompareVersions(eligible.version, current) > 0 {
+		return eligible, true
+	}
+	return newest, newest.version != ""
+}
@@ -0,0 +1,30 @@
+package providers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPickRelease(t *testing.T) {
+	now := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+	releases := []release{
+		{version: "1.0.0", date: now.AddDate(0, 0, -60)},
+		{version: "1.1.0", date: now.AddDate(0, 0, -20)},
+		{version: "1.2.0", date: now.AddDate(0, 0, -10), yanked: true},
+		{version: "1.3.0rc1", date: now.AddDate(0, 0, -5)},
+		{version: "1.3.0", date: now.AddDate(0, 0, -2)},
+	}
+	defer func(age time.Duration) { MinReleaseAge = age }(MinReleaseAge)
+
+	MinReleaseAge = 0
+	if rel, _ := pickRelease(releases, "1.3.0", "1.0.0", now); rel.version != "1.3.0" {
+		t.Errorf("expected latest release, got %s", rel.version)
+	}
+	MinReleaseAge = 7 * 24 * time.Hour
+	if rel, _ := pickRelease(releases, "1.3.0", "1.0.0", now); rel.version != "1.1.0" {
+		t.Errorf("expected cooled down release, got %s", rel.version)
+	}
+	if rel, _ := pickRelease(releases, "1.3.0", "1.1.0", now); rel.version != "1.3.0" {
+		t.Errorf("expected latest release when nothing newer is old enough, got %s", rel.version)
+	}
+}
@@ -0,0 +1,77 @@
+package providers
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/casimir/compulsive"
+)
+
+var rustupCheckRe = regexp.MustCompile(`^(\S+) - (Update available|Up to date) : (\S+)(?:.*-> (\S+))?`)
+
+type Rustup struct{}
+
+func (p *Rustup) Name() string {
+	return "rustup"
+}
+
+func (p *Rustup) IsAvailable() bool {
+	return exec.Command("rustup", "--version").Run() == nil
+}
+
+func (p *Rustup) Sync() error {
+	return nil
+}
+
+func (p *Rustup) BootstrapPackage() string {
+	return "rustup"
+}
+
+func (p *Rustup) List() ([]compulsive.Package, error) {
+	out, err := exec.Command("rustup", "check").Output()
+	if err != nil {
+		return nil, fmt.Errorf("error while checking toolchains: %s", err)
+	}
+	var pkgs []compulsive.Package
+	for _, line := range bytes.Split(out, []byte("\n")) {
+		matches := rustupCheckRe.FindStringSubmatch(string(line))
+		if matches == nil {
+			continue
+		}
+		pkg := compulsive.Package{
+			Provider: p,
+			Name:     matches[1],
+			Label:    matches[1],
+			State:    compulsive.StateUpToDate,
+			Version:  matches[3],
+		}
+		if matches[2] == "Update available" {
+			pkg.State = compulsive.StateOutdated
+			pkg.NextVersion = matches[4]
+		}
+		pkgs = append(pkgs, pkg)
+	}
+	return pkgs, nil
+}
+
+func (p *Rustup) UpdateCommand(pkgs ...compulsive.Package) string {
+	var commands, toolchains []string
+	for _, it := range pkgs {
+		if it.Name == p.BootstrapPackage() {
+			commands = append(commands, "rustup self update")
+		} else {
+			toolchains = append(toolchains, it.Name)
+		}
+	}
+	if len(toolchains) > 0 {
+		commands = append(commands, "rustup update "+strings.Join(toolchains, " "))
+	}
+	return strings.Join(commands, "\n")
+}
+
+func NewRustup() compulsive.Provider {
+	return &Rustup{}
+}
@@ -0,0 +1,24 @@
+package providers
+
+import (
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+var shellSafeRe = regexp.MustCompile(`^[A-Za-z0-9_@+=:,./-]+$`)
+
+// shellQuote quotes an argument of an update command, which is run through
+// sh or cmd. Arguments made of safe characters only are kept as is.
+func shellQuote(arg string) string {
+	if runtime.GOOS == "windows" {
+		if arg != "" && shellSafeRe.MatchString(strings.ReplaceAll(arg, `\`, "/")) {
+			return arg
+		}
+		return `"` + strings.ReplaceAll(arg, `"`, `""`) + `"`
+	}
+	if shellSafeRe.MatchString(arg) {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
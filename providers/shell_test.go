@@ -0,0 +1,31 @@
+package providers
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/casimir/compulsive"
+)
+
+func TestShellQuote(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("cmd quoting")
+	}
+	cases := map[string]string{
+		"/src/project":      "/src/project",
+		"requests==2.32.3":  "requests==2.32.3",
+		"/src/my project":   "'/src/my project'",
+		"/src/it's; rm -rf": `'/src/it'\''s; rm -rf'`,
+		"":                  "''",
+	}
+	for arg, expected := range cases {
+		if got := shellQuote(arg); got != expected {
+			t.Errorf("shellQuote(%q) = %q", arg, got)
+		}
+	}
+	p := &GoProject{root: "/src/$(id)"}
+	pkg := compulsive.Package{Provider: p, Name: "example.com/mod", NextVersion: "v1.2.0"}
+	if got := p.UpdateCommand(pkg); got != "go -C '/src/$(id)' get example.com/mod@v1.2.0" {
+		t.Errorf("got %q", got)
+	}
+}
@@ -0,0 +1,216 @@
+package upgrade
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/casimir/compulsive"
+	"github.com/casimir/compulsive/index"
+)
+
+var ErrNoBackup = errors.New("no backup found for this package")
+
+type (
+	// BackupFile is a binary saved before an upgrade. Target is the file
+	// the binary pointed to when it was a symlink.
+	BackupFile struct {
+		Name   string `json:"name"`
+		Path   string `json:"path"`
+		Target string `json:"target,omitempty"`
+		Mode   uint32 `json:"mode"`
+	}
+
+	// Backup records the state of a package before it was upgraded.
+	Backup struct {
+		Provider string       `json:"provider"`
+		Package  string       `json:"package"`
+		Version  string       `json:"version"`
+		Created  time.Time    `json:"created"`
+		Files    []BackupFile `json:"files"`
+		dir      string
+	}
+)
+
+func backupRoot(provider, pkg string) (string, error) {
+	dir, err := compulsive.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "backups", url.PathEscape(provider), url.PathEscape(pkg)), nil
+}
+
+func copyFile(src, dst string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	tmp, err := ioutil.TempFile(filepath.Dir(dst), ".compulsive-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, in); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dst)
+}
+
+// BackupPackage copies the binaries of a package to a versioned store and
+// records its current version.
+func BackupPackage(pkg compulsive.Package) (Backup, error) {
+	if pkg.Version == "" {
+		return Backup{}, fmt.Errorf("%s has no known version to back up", pkg.Name)
+	}
+	backup := Backup{
+		Provider: pkg.Provider.Name(),
+		Package:  pkg.Name,
+		Version:  pkg.Version,
+		Created:  time.Now(),
+	}
+	root, err := backupRoot(backup.Provider, backup.Package)
+	if err != nil {
+		return backup, err
+	}
+	backup.dir = filepath.Join(root, url.PathEscape(pkg.Version))
+	if err := os.MkdirAll(backup.dir, 0755); err != nil {
+		return backup, err
+	}
+	for _, it := range pkg.Binaries {
+		path := index.LocateBinary(pkg, it)
+		if path == "" {
+			continue
+		}
+		target, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			return backup, fmt.Errorf("%s: %s", it, err)
+		}
+		info, err := os.Stat(target)
+		if err != nil {
+			return backup, err
+		}
+		if err := copyFile(target, filepath.Join(backup.dir, filepath.Base(path)), info.Mode()); err != nil {
+			return backup, fmt.Errorf("could not back up %s: %s", path, err)
+		}
+		file := BackupFile{
+			Name: filepath.Base(path),
+			Path: path,
+			Mode: uint32(info.Mode().Perm()),
+		}
+		if target != path {
+			file.Target = target
+		}
+		backup.Files = append(backup.Files, file)
+	}
+	raw, err := json.MarshalIndent(backup, "", "  ")
+	if err != nil {
+		return backup, err
+	}
+	return backup, ioutil.WriteFile(filepath.Join(backup.dir, "backup.json"), raw, 0644)
+}
+
+// ListBackups gives the backups of a package, most recent first.
+func ListBackups(provider, pkg string) ([]Backup, error) {
+	root, err := backupRoot(provider, pkg)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := ioutil.ReadDir(root)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	var backups []Backup
+	for _, it := range entries {
+		dir := filepath.Join(root, it.Name())
+		raw, err := ioutil.ReadFile(filepath.Join(dir, "backup.json"))
+		if err != nil {
+			continue
+		}
+		var backup Backup
+		if err := json.Unmarshal(raw, &backup); err != nil {
+			continue
+		}
+		backup.dir = dir
+		backups = append(backups, backup)
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].Created.After(backups[j].Created) })
+	return backups, nil
+}
+
+// LatestBackup gives the most recent backup of a version other than the
+// installed one.
+func LatestBackup(pkg compulsive.Package) (Backup, error) {
+	backups, err := ListBackups(pkg.Provider.Name(), pkg.Name)
+	if err != nil {
+		return Backup{}, err
+	}
+	for _, it := range backups {
+		if it.Version != pkg.Version {
+			return it, nil
+		}
+	}
+	return Backup{}, ErrNoBackup
+}
+
+// Restore puts the saved binaries back where they were found. Symlinked
+// binaries are restored to the file they pointed to at the time, the link
+// being recreated as the upgrade may have pointed it elsewhere.
+func (b Backup) Restore() error {
+	if len(b.Files) == 0 {
+		return fmt.Errorf("backup of %s %s holds no binaries", b.Package, b.Version)
+	}
+	for _, it := range b.Files {
+		if err := it.restore(b.dir); err != nil {
+			return fmt.Errorf("could not restore %s: %s", it.Path, err)
+		}
+	}
+	return nil
+}
+
+func (f BackupFile) restore(dir string) error {
+	dst := f.Path
+	if f.Target != "" {
+		dst = f.Target
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	if err := copyFile(filepath.Join(dir, f.Name), dst, os.FileMode(f.Mode)); err != nil {
+		return err
+	}
+	if f.Target == "" {
+		return nil
+	}
+	if current, err := filepath.EvalSymlinks(f.Path); err == nil && current == f.Target {
+		return nil
+	}
+	if err := os.Remove(f.Path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.Symlink(f.Target, f.Path)
+}
+
+// RollbackCommand gives the provider command installing back the version
+// of the backup, or an empty string if the provider cannot do it.
+func (b Backup) RollbackCommand(pkg compulsive.Package) string {
+	installer, ok := pkg.Provider.(compulsive.VersionInstaller)
+	if !ok {
+		return ""
+	}
+	return installer.InstallVersionCommand(pkg, b.Version)
+}
@@ -0,0 +1,77 @@
+package upgrade
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/casimir/compulsive"
+)
+
+func TestBackupRestore(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", filepath.Join(root, "data"))
+	bin := filepath.Join(root, "bin")
+	t.Setenv("PATH", bin)
+	oldTarget := filepath.Join(root, "Cellar", "tool", "1.0", "tool")
+	for _, it := range []string{bin, filepath.Dir(oldTarget)} {
+		if err := os.MkdirAll(it, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ioutil.WriteFile(oldTarget, []byte("1.0"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(bin, "tool")
+	if err := os.Symlink(oldTarget, link); err != nil {
+		t.Fatal(err)
+	}
+	pkg := compulsive.Package{Provider: fakeProvider{name: "brew"}, Name: "tool", Version: "1.0", Binaries: []string{"tool"}}
+	if _, err := BackupPackage(pkg); err != nil {
+		t.Fatal(err)
+	}
+
+	// the upgrade installs a new version and removes the old one
+	newTarget := filepath.Join(root, "Cellar", "tool", "2.0", "tool")
+	if err := os.MkdirAll(filepath.Dir(newTarget), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(newTarget, []byte("2.0"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	os.RemoveAll(filepath.Dir(oldTarget))
+	os.Remove(link)
+	if err := os.Symlink(newTarget, link); err != nil {
+		t.Fatal(err)
+	}
+
+	pkg.Version = "2.0"
+	backup, err := LatestBackup(pkg)
+	if err != nil || backup.Version != "1.0" || len(backup.Files) != 1 || backup.Files[0].Target != oldTarget {
+		t.Fatalf("got %+v, %v", backup, err)
+	}
+	if err := backup.Restore(); err != nil {
+		t.Fatal(err)
+	}
+	if target, _ := os.Readlink(link); target != oldTarget {
+		t.Errorf("link points to %q", target)
+	}
+	if raw, _ := ioutil.ReadFile(link); string(raw) != "1.0" {
+		t.Errorf("restored %q", raw)
+	}
+	if raw, _ := ioutil.ReadFile(newTarget); string(raw) != "2.0" {
+		t.Errorf("new version overwritten with %q", raw)
+	}
+}
+
+func TestBackupNeedsVersion(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	pkg := compulsive.Package{Provider: fakeProvider{name: "brew"}, Name: "tool"}
+	if _, err := BackupPackage(pkg); err == nil {
+		t.Error("expected an error for a package without version")
+	}
+	if _, err := LatestBackup(pkg); err != ErrNoBackup {
+		t.Errorf("got %v", err)
+	}
+}
@@ -0,0 +1,124 @@
+package upgrade
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"time"
+
+	"github.com/casimir/compulsive"
+)
+
+type (
+	// Entry records the execution of an update command.
+	Entry struct {
+		Time        time.Time    `json:"time"`
+		User        string       `json:"user"`
+		Provider    string       `json:"provider"`
+		Command     string       `json:"command"`
+		Transitions []Transition `json:"transitions"`
+		Duration    float64      `json:"duration"`
+		ExitStatus  int          `json:"exit_status"`
+		Error       string       `json:"error,omitempty"`
+	}
+
+	// HistoryFilter selects history entries, zero fields matching anything.
+	HistoryFilter struct {
+		Provider string
+		Package  string
+		Since    time.Time
+		Until    time.Time
+	}
+)
+
+func historyPath() (string, error) {
+	dir, err := compulsive.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "history.jsonl"), nil
+}
+
+func currentUser() string {
+	if usr, err := user.Current(); err == nil {
+		return usr.Username
+	}
+	return os.Getenv("USER")
+}
+
+func exitStatus(err error) int {
+	if err == nil {
+		return 0
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+// AppendHistory adds an entry at the end of the history log.
+func AppendHistory(entry Entry) error {
+	path, err := historyPath()
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return json.NewEncoder(f).Encode(entry)
+}
+
+// ReadHistory gives the entries of the history log matching the filter,
+// oldest first.
+func ReadHistory(filter HistoryFilter) ([]Entry, error) {
+	path, err := historyPath()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if filter.Match(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+func (f HistoryFilter) Match(entry Entry) bool {
+	if f.Provider != "" && entry.Provider != f.Provider {
+		return false
+	}
+	if !f.Since.IsZero() && entry.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !entry.Time.Before(f.Until) {
+		return false
+	}
+	if f.Package == "" {
+		return true
+	}
+	for _, it := range entry.Transitions {
+		if it.Package == f.Package || it.Provider+"/"+it.Package == f.Package {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,40 @@
+package upgrade
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistoryFilterMatch(t *testing.T) {
+	at := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	entry := Entry{
+		Time:     at,
+		Provider: "brew",
+		Transitions: []Transition{
+			{Provider: "brew", Package: "jq", From: "1.7", To: "1.7.1"},
+			{Provider: "brew", Package: "fd", From: "8.7.0", To: "9.0.0"},
+		},
+	}
+	cases := []struct {
+		filter   HistoryFilter
+		expected bool
+	}{
+		{HistoryFilter{}, true},
+		{HistoryFilter{Provider: "brew"}, true},
+		{HistoryFilter{Provider: "cargo"}, false},
+		{HistoryFilter{Package: "fd"}, true},
+		{HistoryFilter{Package: "brew/jq"}, true},
+		{HistoryFilter{Package: "cargo/jq"}, false},
+		{HistoryFilter{Package: "rg"}, false},
+		{HistoryFilter{Since: at}, true},
+		{HistoryFilter{Since: at.Add(time.Second)}, false},
+		{HistoryFilter{Until: at.Add(time.Second)}, true},
+		{HistoryFilter{Until: at}, false},
+		{HistoryFilter{Provider: "brew", Package: "jq", Since: at.AddDate(0, 0, -1), Until: at.AddDate(0, 0, 1)}, true},
+	}
+	for i, it := range cases {
+		if got := it.filter.Match(entry); got != it.expected {
+			t.Errorf("case %d: Match(%+v) = %v", i, it.filter, got)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package upgrade
+
+import (
+	"github.com/casimir/compulsive"
+)
+
+// orderProviders sorts provider groups so that every provider comes after
+// the providers it depends on, keeping name order otherwise. Dependency
+// cycles are broken by name order.
+func orderProviders(groups [][]compulsive.Package) [][]compulsive.Package {
+	indexes := make(map[string]int, len(groups))
+	for i, it := range groups {
+		indexes[it[0].Provider.Name()] = i
+	}
+	var ordered [][]compulsive.Package
+	done := make([]bool, len(groups))
+	visiting := make([]bool, len(groups))
+	var visit func(int)
+	visit = func(i int) {
+		if done[i] || visiting[i] {
+			return
+		}
+		visiting[i] = true
+		if dependent, ok := groups[i][0].Provider.(compulsive.DependentProvider); ok {
+			for _, name := range dependent.Dependencies() {
+				if j, ok := indexes[name]; ok {
+					visit(j)
+				}
+			}
+		}
+		visiting[i] = false
+		done[i] = true
+		ordered = append(ordered, groups[i])
+	}
+	for i := range groups {
+		visit(i)
+	}
+	return ordered
+}
+
+// splitBootstrap moves the package manager of a provider in a group of its
+// own, before the other packages of the provider.
+func splitBootstrap(group []compulsive.Package) [][]compulsive.Package {
+	selfManaged, ok := group[0].Provider.(compulsive.SelfManagedProvider)
+	if !ok {
+		return [][]compulsive.Package{group}
+	}
+	var bootstrap, others []compulsive.Package
+	for _, it := range group {
+		if it.Name == selfManaged.BootstrapPackage() {
+			bootstrap = append(bootstrap, it)
+		} else {
+			others = append(others, it)
+		}
+	}
+	if len(bootstrap) == 0 || len(others) == 0 {
+		return [][]compulsive.Package{group}
+	}
+	return [][]compulsive.Package{bootstrap, others}
+}
+
+// Schedule groups packages by provider in the order their update commands
+// must run: providers after the ones they depend on and package managers
+// before the packages they manage.
+func Schedule(pkgs []compulsive.Package) [][]compulsive.Package {
+	var groups [][]compulsive.Package
+	for _, it := range orderProviders(GroupByProvider(pkgs)) {
+		groups = append(groups, splitBootstrap(it)...)
+	}
+	return groups
+}
@@ -0,0 +1,61 @@
+package upgrade
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/casimir/compulsive"
+)
+
+type fakeProvider struct {
+	name      string
+	deps      []string
+	bootstrap string
+	command   string
+	lock      string
+}
+
+func (p fakeProvider) Name() string                        { return p.name }
+func (p fakeProvider) IsAvailable() bool                   { return true }
+func (p fakeProvider) Sync() error                         { return nil }
+func (p fakeProvider) List() ([]compulsive.Package, error) { return nil, nil }
+func (p fakeProvider) Dependencies() []string              { return p.deps }
+func (p fakeProvider) BootstrapPackage() string            { return p.bootstrap }
+
+func (p fakeProvider) LockKey() string { return p.lock }
+
+func (p fakeProvider) UpdateCommand(pkgs ...compulsive.Package) string {
+	return p.command
+}
+
+func TestSchedule(t *testing.T) {
+	brew := fakeProvider{name: "brew"}
+	cargo := fakeProvider{name: "cargo", deps: []string{"rustup"}}
+	pip := fakeProvider{name: "pip", deps: []string{"brew"}, bootstrap: "pip"}
+	rustup := fakeProvider{name: "rustup", bootstrap: "rustup"}
+	pkgs := []compulsive.Package{
+		{Provider: pip, Name: "black"},
+		{Provider: cargo, Name: "ripgrep"},
+		{Provider: pip, Name: "pip"},
+		{Provider: rustup, Name: "stable"},
+		{Provider: brew, Name: "python"},
+	}
+	var got [][]string
+	for _, group := range Schedule(pkgs) {
+		var names []string
+		for _, it := range group {
+			names = append(names, it.Provider.Name()+"/"+it.Name)
+		}
+		got = append(got, names)
+	}
+	expected := [][]string{
+		{"brew/python"},
+		{"rustup/stable"},
+		{"cargo/ripgrep"},
+		{"pip/pip"},
+		{"pip/black"},
+	}
+	if !reflect.DeepEqual(expected, got) {
+		t.Errorf("unexpected order: %v", got)
+	}
+}
@@ -0,0 +1,178 @@
+package upgrade
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/casimir/compulsive"
+)
+
+// ExecOptions tunes how update commands are executed.
+type ExecOptions struct {
+	// Concurrency is the number of providers upgraded at the same time.
+	Concurrency int
+	// LogDir, when set, receives the full output of each provider in a
+	// <provider>.log file.
+	LogDir string
+	// Escalation is used for the commands needing root privileges.
+	Escalation Escalation
+}
+
+type stepRun struct {
+	step   Step
+	group  []compulsive.Package
+	deps   []int
+	done   chan struct{}
+	output bytes.Buffer
+	err    error
+}
+
+// lockKey gives the lock a provider holds while upgrading, providers
+// sharing the same key being never run concurrently.
+func lockKey(pvd compulsive.Provider) string {
+	if locking, ok := pvd.(compulsive.LockingProvider); ok {
+		return locking.LockKey()
+	}
+	return pvd.Name()
+}
+
+// prefixWriter prefixes every line written to out, lines from concurrent
+// writers being kept whole.
+type prefixWriter struct {
+	mu     *sync.Mutex
+	out    io.Writer
+	prefix string
+	buf    []byte
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.mu.Lock()
+		fmt.Fprintf(w.out, "%s%s\n", w.prefix, w.buf[:i])
+		w.mu.Unlock()
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+func (w *prefixWriter) Flush() {
+	if len(w.buf) > 0 {
+		w.Write([]byte("\n"))
+	}
+}
+
+func openLog(dir, provider string) (io.WriteCloser, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, url.PathEscape(provider)+".log")
+	return os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+}
+
+// planRuns turns scheduled groups into runs, each run waiting for the
+// previous runs of the same provider and of the providers it depends on.
+func planRuns(groups [][]compulsive.Package) []*stepRun {
+	var runs []*stepRun
+	for i, group := range groups {
+		run := &stepRun{step: NewStep(group), group: group, done: make(chan struct{})}
+		pvd := group[0].Provider
+		deps := map[string]bool{pvd.Name(): true}
+		if dependent, ok := pvd.(compulsive.DependentProvider); ok {
+			for _, it := range dependent.Dependencies() {
+				deps[it] = true
+			}
+		}
+		for j := 0; j < i; j++ {
+			if deps[runs[j].step.Provider] {
+				run.deps = append(run.deps, j)
+			}
+		}
+		runs = append(runs, run)
+	}
+	return runs
+}
+
+func (r *stepRun) execute(out io.Writer, prefixed bool, mu *sync.Mutex, opts ExecOptions) {
+	writers := []io.Writer{&r.output}
+	var pw *prefixWriter
+	if prefixed {
+		pw = &prefixWriter{mu: mu, out: out, prefix: "[" + r.step.Provider + "] "}
+		writers = append(writers, pw)
+	} else {
+		writers = append(writers, out)
+	}
+	logFile, err := openLog(opts.LogDir, r.step.Provider)
+	if err != nil {
+		mu.Lock()
+		fmt.Fprintf(out, "could not open log for %s: %s\n", r.step.Provider, err)
+		mu.Unlock()
+	} else if logFile != nil {
+		defer logFile.Close()
+		writers = append(writers, logFile)
+	}
+	w := io.MultiWriter(writers...)
+	fmt.Fprintln(w, "$ "+r.step.Command)
+	r.err = RunStep(r.step, opts, w, w)
+	if r.err != nil {
+		fmt.Fprintf(w, "error: %s\n", r.err)
+	}
+	if pw != nil {
+		pw.Flush()
+	}
+}
+
+// runGroups executes the update commands of scheduled groups, running up
+// to opts.Concurrency providers at the same time.
+func runGroups(groups [][]compulsive.Package, out io.Writer, opts ExecOptions) []*stepRun {
+	runs := planRuns(groups)
+	concurrency := opts.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	var outMu, privilegedMu sync.Mutex
+	locks := make(map[string]*sync.Mutex)
+	for _, it := range runs {
+		key := lockKey(it.group[0].Provider)
+		if _, ok := locks[key]; !ok {
+			locks[key] = &sync.Mutex{}
+		}
+	}
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for _, run := range runs {
+		wg.Add(1)
+		go func(run *stepRun) {
+			defer wg.Done()
+			defer close(run.done)
+			for _, dep := range run.deps {
+				<-runs[dep].done
+			}
+			lock := locks[lockKey(run.group[0].Provider)]
+			lock.Lock()
+			defer lock.Unlock()
+			if run.step.Privileged {
+				// escalation may prompt for a password, one at a time
+				privilegedMu.Lock()
+				defer privilegedMu.Unlock()
+			}
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			run.execute(out, concurrency > 1, &outMu, opts)
+		}(run)
+	}
+	wg.Wait()
+	return runs
+}
@@ -0,0 +1,46 @@
+package upgrade
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/casimir/compulsive"
+)
+
+func TestRunGroups(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	logDir := t.TempDir()
+	pip := fakeProvider{name: "pip", lock: "site", command: "echo pip"}
+	pip3 := fakeProvider{name: "pip3", lock: "site", command: "echo pip3"}
+	brew := fakeProvider{name: "brew", lock: "brew", command: "echo brew && false"}
+	pkgs := []compulsive.Package{
+		{Provider: pip, Name: "black"},
+		{Provider: pip3, Name: "black"},
+		{Provider: brew, Name: "ripgrep"},
+	}
+	var out bytes.Buffer
+	runs := runGroups(Schedule(pkgs), &out, ExecOptions{Concurrency: 3, LogDir: logDir})
+	for _, it := range runs {
+		if failed := it.step.Provider == "brew"; failed != (it.err != nil) {
+			t.Errorf("%s: unexpected error: %v", it.step.Provider, it.err)
+		}
+	}
+	if !strings.Contains(out.String(), "[pip3] pip3\n") {
+		t.Errorf("missing prefixed output in %q", out.String())
+	}
+	raw, err := ioutil.ReadFile(filepath.Join(logDir, "brew.log"))
+	if err != nil || !strings.Contains(string(raw), "brew\n") {
+		t.Errorf("missing provider log: %v", err)
+	}
+}
+
+func TestRunStepWithoutCommand(t *testing.T) {
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	step := Step{Provider: "pip", Command: ""}
+	if err := RunStep(step, ExecOptions{}, ioutil.Discard, ioutil.Discard); err == nil {
+		t.Errorf("expected an error for a step without command")
+	}
+}
@@ -0,0 +1,168 @@
+package upgrade
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/casimir/compulsive"
+	"github.com/casimir/compulsive/index"
+)
+
+type (
+	// Transition is the version change expected for a package.
+	Transition struct {
+		Provider string `json:"provider"`
+		Package  string `json:"package"`
+		From     string `json:"from"`
+		To       string `json:"to"`
+	}
+
+	// Step is an update command along with the transitions it performs.
+	Step struct {
+		Provider    string       `json:"provider"`
+		Command     string       `json:"command"`
+		Privileged  bool         `json:"privileged,omitempty"`
+		Transitions []Transition `json:"transitions"`
+	}
+
+	// Plan is a reviewable list of upgrades to apply on a given host.
+	// Project is the root of the project the plan upgrades the dependencies
+	// of, if any.
+	Plan struct {
+		Created time.Time `json:"created"`
+		Host    string    `json:"host"`
+		Project string    `json:"project,omitempty"`
+		Steps   []Step    `json:"steps"`
+	}
+)
+
+// GroupByProvider splits packages per provider, providers being sorted by
+// name and packages by name.
+func GroupByProvider(pkgs []compulsive.Package) [][]compulsive.Package {
+	groups := make(map[string][]compulsive.Package)
+	var names []string
+	for _, it := range pkgs {
+		name := it.Provider.Name()
+		if _, ok := groups[name]; !ok {
+			names = append(names, name)
+		}
+		groups[name] = append(groups[name], it)
+	}
+	sort.Strings(names)
+	var list [][]compulsive.Package
+	for _, name := range names {
+		group := groups[name]
+		sort.Slice(group, func(i, j int) bool { return group[i].Name < group[j].Name })
+		list = append(list, group)
+	}
+	return list
+}
+
+// NewPlan builds a plan upgrading the given packages.
+func NewPlan(pkgs []compulsive.Package) Plan {
+	host, _ := os.Hostname()
+	plan := Plan{Created: time.Now(), Host: host}
+	for _, group := range Schedule(pkgs) {
+		plan.Steps = append(plan.Steps, NewStep(group))
+	}
+	return plan
+}
+
+// NewStep builds the step upgrading packages of the same provider.
+func NewStep(pkgs []compulsive.Package) Step {
+	pvd := pkgs[0].Provider
+	step := Step{
+		Provider:   pvd.Name(),
+		Command:    pvd.UpdateCommand(pkgs...),
+		Privileged: NeedsRoot(pvd, compulsive.OpUpdate),
+	}
+	for _, it := range pkgs {
+		step.Transitions = append(step.Transitions, Transition{
+			Provider: pvd.Name(),
+			Package:  it.Name,
+			From:     it.Version,
+			To:       it.NextVersion,
+		})
+	}
+	return step
+}
+
+// Providers gives the names of the providers involved in the plan.
+func (p Plan) Providers() []string {
+	var names []string
+	for _, it := range p.Steps {
+		names = append(names, it.Provider)
+	}
+	return names
+}
+
+// CheckDrift makes sure the packages of the plan are still in the state
+// they were when the plan was made, and that their providers would still
+// run the planned commands.
+func (p Plan) CheckDrift(idx index.Index) error {
+	if host, _ := os.Hostname(); host != p.Host {
+		return fmt.Errorf("plan was made for host %q", p.Host)
+	}
+	var drifts []string
+	for _, step := range p.Steps {
+		pvd, ok := idx.FindProviderByName(step.Provider)
+		if !ok {
+			drifts = append(drifts, step.Provider+": provider unavailable")
+			continue
+		}
+		var pkgs []compulsive.Package
+		drifted := false
+		for _, it := range step.Transitions {
+			id := it.Provider + "/" + it.Package
+			pkg, ok := idx[pvd][it.Package]
+			switch {
+			case !ok:
+				drifts = append(drifts, id+": not installed anymore")
+			case pkg.Version != it.From:
+				drifts = append(drifts, fmt.Sprintf("%s: installed version is now %s", id, pkg.Version))
+			case pkg.NextVersion != it.To:
+				drifts = append(drifts, fmt.Sprintf("%s: available version is now %s", id, pkg.NextVersion))
+			default:
+				pkgs = append(pkgs, pkg)
+				continue
+			}
+			drifted = true
+		}
+		if !drifted && len(pkgs) > 0 {
+			if command := pvd.UpdateCommand(pkgs...); command != step.Command {
+				drifts = append(drifts, fmt.Sprintf("%s: command is now %q", step.Provider, command))
+			}
+		}
+	}
+	if len(drifts) > 0 {
+		return fmt.Errorf("machine state has drifted since the plan was made:\n  %s", strings.Join(drifts, "\n  "))
+	}
+	return nil
+}
+
+// Write saves the plan to path.
+func (p Plan) Write(path string) error {
+	raw, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(raw, '\n'), 0644)
+}
+
+// ReadPlan loads a plan previously written to path.
+func ReadPlan(path string) (Plan, error) {
+	var plan Plan
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return plan, err
+	}
+	if err := json.Unmarshal(raw, &plan); err != nil {
+		return plan, fmt.Errorf("invalid plan file: %s", err)
+	}
+	return plan, nil
+}
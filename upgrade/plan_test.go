@@ -0,0 +1,98 @@
+package upgrade
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/casimir/compulsive"
+	"github.com/casimir/compulsive/index"
+)
+
+func TestNewPlan(t *testing.T) {
+	brew := &fakeProvider{name: "brew", command: "brew upgrade fd jq"}
+	cargo := &fakeProvider{name: "cargo", deps: []string{"brew"}, command: "cargo install --force rg"}
+	plan := NewPlan([]compulsive.Package{
+		{Provider: cargo, Name: "rg", Version: "13.0.0", NextVersion: "14.0.0"},
+		{Provider: brew, Name: "jq", Version: "1.7", NextVersion: "1.7.1"},
+		{Provider: brew, Name: "fd", Version: "8.7.0", NextVersion: "9.0.0"},
+	})
+	if host, _ := os.Hostname(); plan.Host != host {
+		t.Errorf("got host %q", plan.Host)
+	}
+	expected := []Step{
+		{Provider: "brew", Command: "brew upgrade fd jq", Transitions: []Transition{
+			{Provider: "brew", Package: "fd", From: "8.7.0", To: "9.0.0"},
+			{Provider: "brew", Package: "jq", From: "1.7", To: "1.7.1"},
+		}},
+		{Provider: "cargo", Command: "cargo install --force rg", Transitions: []Transition{
+			{Provider: "cargo", Package: "rg", From: "13.0.0", To: "14.0.0"},
+		}},
+	}
+	if !reflect.DeepEqual(plan.Steps, expected) {
+		t.Errorf("got %+v", plan.Steps)
+	}
+	if got := plan.Providers(); !reflect.DeepEqual(got, []string{"brew", "cargo"}) {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestCheckDrift(t *testing.T) {
+	brew := &fakeProvider{name: "brew", command: "brew upgrade jq"}
+	jq := compulsive.Package{Provider: brew, Name: "jq", Version: "1.7", NextVersion: "1.7.1"}
+	plan := NewPlan([]compulsive.Package{jq})
+	idx := index.Index{brew: {"jq": jq}}
+	if err := plan.CheckDrift(idx); err != nil {
+		t.Errorf("unexpected drift: %s", err)
+	}
+
+	brew.command = "sudo brew upgrade jq"
+	if err := plan.CheckDrift(idx); err == nil || !strings.Contains(err.Error(), `brew: command is now "sudo brew upgrade jq"`) {
+		t.Errorf("got %v", err)
+	}
+	brew.command = "brew upgrade jq"
+
+	jq.NextVersion = "1.8.0"
+	idx[brew]["jq"] = jq
+	if err := plan.CheckDrift(idx); err == nil || !strings.Contains(err.Error(), "brew/jq: available version is now 1.8.0") {
+		t.Errorf("got %v", err)
+	}
+	delete(idx[brew], "jq")
+	if err := plan.CheckDrift(idx); err == nil || !strings.Contains(err.Error(), "brew/jq: not installed anymore") {
+		t.Errorf("got %v", err)
+	}
+	if err := plan.CheckDrift(index.Index{}); err == nil || !strings.Contains(err.Error(), "brew: provider unavailable") {
+		t.Errorf("got %v", err)
+	}
+
+	plan.Host = "elsewhere"
+	if err := plan.CheckDrift(idx); err == nil || !strings.Contains(err.Error(), "elsewhere") {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestPlanReadWrite(t *testing.T) {
+	brew := &fakeProvider{name: "brew", command: "brew upgrade jq"}
+	plan := NewPlan([]compulsive.Package{{Provider: brew, Name: "jq", Version: "1.7", NextVersion: "1.7.1"}})
+	plan.Project = "/src/project"
+	path := filepath.Join(t.TempDir(), "plan.json")
+	if err := plan.Write(path); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadPlan(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Created.Equal(plan.Created) || got.Project != plan.Project || !reflect.DeepEqual(got.Steps, plan.Steps) {
+		t.Errorf("got %+v", got)
+	}
+	if err := ioutil.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadPlan(path); err == nil {
+		t.Error("expected an error for an invalid plan")
+	}
+}
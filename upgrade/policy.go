@@ -0,0 +1,83 @@
+package upgrade
+
+import (
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/casimir/compulsive"
+	"github.com/casimir/compulsive/config"
+)
+
+const (
+	ActionApply   = "apply"
+	ActionConfirm = "confirm"
+	ActionSkip    = "skip"
+)
+
+// Decision is the outcome of a policy for an update.
+type Decision struct {
+	Package compulsive.Package
+	Action  string
+	Reason  string
+}
+
+func matchAny(value string, patterns []string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, it := range patterns {
+		if ok, _ := path.Match(it, value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// checkAge skips updates released too recently, packages whose providers
+// do not know release dates being let through.
+func (d *Decision) checkAge(minAgeDays int, now time.Time) {
+	if minAgeDays <= 0 || d.Action == ActionSkip || d.Package.Released.IsZero() {
+		return
+	}
+	age := now.Sub(d.Package.Released)
+	if minAge := time.Duration(minAgeDays) * 24 * time.Hour; age < minAge {
+		d.Action = ActionSkip
+		d.Reason = fmt.Sprintf("released %d days ago, %d days required", int(age.Hours()/24), minAgeDays)
+	}
+}
+
+// Evaluate decides what to do with an outdated package according to a
+// policy.
+func Evaluate(policy config.Policy, pkg compulsive.Package, now time.Time) Decision {
+	change := compulsive.ClassifyChange(pkg.Version, pkg.NextVersion)
+	id := pkg.Provider.Name() + "/" + pkg.Name
+	decision := Decision{Package: pkg, Action: policy.Default}
+	if decision.Action == "" {
+		decision.Action = ActionConfirm
+	}
+	decision.Reason = fmt.Sprintf("%s update, no matching rule", change)
+	minAgeDays := policy.MinAgeDays
+	for i, rule := range policy.Rules {
+		if !matchAny(pkg.Provider.Name(), rule.Providers) || !matchAny(id, rule.Packages) {
+			continue
+		}
+		if rule.Change != "" && rule.Change != string(change) {
+			continue
+		}
+		decision.Action = rule.Action
+		decision.Reason = fmt.Sprintf("%s update, rule %d", change, i+1)
+		if rule.MinAgeDays > minAgeDays {
+			minAgeDays = rule.MinAgeDays
+		}
+		break
+	}
+	switch decision.Action {
+	case ActionApply, ActionConfirm, ActionSkip:
+	default:
+		decision.Reason = fmt.Sprintf("unknown action %q", decision.Action)
+		decision.Action = ActionSkip
+	}
+	decision.checkAge(minAgeDays, now)
+	return decision
+}
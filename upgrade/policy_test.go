@@ -0,0 +1,41 @@
+package upgrade
+
+import (
+	"testing"
+	"time"
+
+	"github.com/casimir/compulsive"
+	"github.com/casimir/compulsive/config"
+)
+
+func TestEvaluate(t *testing.T) {
+	policy := config.Policy{
+		Rules: []config.Rule{
+			{Providers: []string{"cargo", "pip"}, Change: "patch", Action: ActionApply},
+			{Change: "minor", Action: ActionConfirm},
+			{Change: "major", Action: ActionSkip},
+		},
+		Default:    ActionConfirm,
+		MinAgeDays: 7,
+	}
+	now := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+	old := now.AddDate(0, 0, -30)
+	cargo := fakeProvider{name: "cargo"}
+	brew := fakeProvider{name: "brew"}
+	cases := []struct {
+		pkg      compulsive.Package
+		expected string
+	}{
+		{compulsive.Package{Provider: cargo, Version: "1.2.3", NextVersion: "1.2.4", Released: old}, ActionApply},
+		{compulsive.Package{Provider: cargo, Version: "1.2.3", NextVersion: "1.2.4", Released: now}, ActionSkip},
+		{compulsive.Package{Provider: cargo, Version: "1.2.3", NextVersion: "1.2.4"}, ActionApply},
+		{compulsive.Package{Provider: brew, Version: "1.2.3", NextVersion: "1.2.4", Released: old}, ActionConfirm},
+		{compulsive.Package{Provider: cargo, Version: "1.2.3", NextVersion: "1.3.0", Released: old}, ActionConfirm},
+		{compulsive.Package{Provider: cargo, Version: "1.2.3", NextVersion: "2.0.0", Released: old}, ActionSkip},
+	}
+	for i, it := range cases {
+		if got := Evaluate(policy, it.pkg, now); got.Action != it.expected {
+			t.Errorf("case %d: got %s (%s), expected %s", i, got.Action, got.Reason, it.expected)
+		}
+	}
+}
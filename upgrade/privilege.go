@@ -0,0 +1,48 @@
+package upgrade
+
+import (
+	"fmt"
+	"os/exec"
+
+	"github.com/casimir/compulsive"
+)
+
+const (
+	EscalateRefuse = "refuse"
+	EscalateSudo   = "sudo"
+	EscalateDoas   = "doas"
+	EscalateUser   = "user"
+)
+
+// Escalation tells how commands needing root privileges are run.
+type Escalation struct {
+	Strategy string
+	User     string
+}
+
+// NeedsRoot tells whether an operation of a provider needs root privileges.
+func NeedsRoot(pvd compulsive.Provider, op compulsive.Operation) bool {
+	privileged, ok := pvd.(compulsive.PrivilegedProvider)
+	return ok && privileged.NeedsRoot(op)
+}
+
+// command builds the command running a line of a privileged step. Only the
+// line itself is escalated, compulsive keeps running as the current user.
+func (e Escalation) command(line string) (*exec.Cmd, error) {
+	if e.Strategy == EscalateUser {
+		if e.User == "" {
+			return nil, fmt.Errorf("no user configured to run %q", line)
+		}
+		return exec.Command("sudo", "-u", e.User, "-H", "sh", "-c", line), nil
+	}
+	if compulsive.CheckSudo() == nil {
+		return shellCommand(line), nil
+	}
+	switch e.Strategy {
+	case EscalateSudo, EscalateDoas:
+		return exec.Command(e.Strategy, "sh", "-c", line), nil
+	case EscalateRefuse, "":
+		return nil, fmt.Errorf("%s: %q must be run as root (set the privilege strategy to sudo, doas or user to escalate it)", compulsive.ErrSudoNeeded, line)
+	}
+	return nil, fmt.Errorf("unknown privilege strategy: %s", e.Strategy)
+}
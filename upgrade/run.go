@@ -0,0 +1,76 @@
+package upgrade
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+func shellCommand(line string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", line)
+	}
+	return exec.Command("sh", "-c", line)
+}
+
+// RunCommand executes an update command line by line through the shell,
+// stopping at the first failing line.
+func RunCommand(command string, stdout, stderr io.Writer) error {
+	return runCommand(command, nil, stdout, stderr)
+}
+
+func runCommand(command string, esc *Escalation, stdout, stderr io.Writer) error {
+	for _, line := range strings.Split(command, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		cmd := shellCommand(line)
+		if esc != nil {
+			var err error
+			if cmd, err = esc.command(line); err != nil {
+				return err
+			}
+			cmd.Stdin = os.Stdin
+		}
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
+		if err := cmd.Run(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunStep executes the command of a step and records it in the history.
+// Commands of privileged steps are escalated as configured in opts.
+func RunStep(step Step, opts ExecOptions, stdout, stderr io.Writer) error {
+	if strings.TrimSpace(step.Command) == "" {
+		return fmt.Errorf("%s cannot update these packages", step.Provider)
+	}
+	var esc *Escalation
+	if step.Privileged {
+		esc = &opts.Escalation
+	}
+	start := time.Now()
+	err := runCommand(step.Command, esc, stdout, stderr)
+	entry := Entry{
+		Time:        start,
+		User:        currentUser(),
+		Provider:    step.Provider,
+		Command:     step.Command,
+		Transitions: step.Transitions,
+		Duration:    time.Since(start).Seconds(),
+		ExitStatus:  exitStatus(err),
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	if histErr := AppendHistory(entry); histErr != nil {
+		fmt.Fprintf(stderr, "could not record history: %s\n", histErr)
+	}
+	return err
+}
@@ -0,0 +1,71 @@
+package upgrade
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/casimir/compulsive"
+	"github.com/casimir/compulsive/index"
+)
+
+type Outcome string
+
+const (
+	OutcomeUpgraded  Outcome = "upgraded"
+	OutcomeUnchanged Outcome = "unchanged"
+	OutcomeFailed    Outcome = "failed"
+)
+
+// Result is the effect an upgrade had on a package.
+type Result struct {
+	Package    compulsive.Package
+	NewVersion string
+	Outcome    Outcome
+	Output     string
+	Err        error
+}
+
+// Execute runs the update commands of the given packages, provider by
+// provider, then lists the affected providers again to check that the
+// installed versions actually changed. Command output is copied to out as
+// it is produced.
+func Execute(pkgs []compulsive.Package, out io.Writer, opts ExecOptions) ([]Result, error) {
+	var results []Result
+	var names []string
+	for _, run := range runGroups(Schedule(pkgs), out, opts) {
+		names = append(names, run.step.Provider)
+		for _, it := range run.group {
+			results = append(results, Result{Package: it, Output: run.output.String(), Err: run.err})
+		}
+	}
+	idx, err := index.NewFor(names, false)
+	if err != nil {
+		return results, fmt.Errorf("could not list packages after upgrade: %s", err)
+	}
+	for i := range results {
+		results[i].Outcome = checkOutcome(idx, &results[i])
+	}
+	return results, nil
+}
+
+func checkOutcome(idx index.Index, res *Result) Outcome {
+	if res.Err != nil {
+		return OutcomeFailed
+	}
+	pvd, ok := idx.FindProviderByName(res.Package.Provider.Name())
+	if !ok {
+		res.Err = compulsive.ErrProviderUnavailable
+		return OutcomeFailed
+	}
+	pkg, ok := idx[pvd][res.Package.Name]
+	if !ok {
+		res.Err = compulsive.ErrPackageNotFound
+		return OutcomeFailed
+	}
+	res.NewVersion = pkg.Version
+	if pkg.Version == res.Package.Version {
+		return OutcomeUnchanged
+	}
+	res.Output = ""
+	return OutcomeUpgraded
+}
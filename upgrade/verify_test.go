@@ -0,0 +1,40 @@
+package upgrade
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/casimir/compulsive"
+	"github.com/casimir/compulsive/index"
+)
+
+func TestCheckOutcome(t *testing.T) {
+	brew := &fakeProvider{name: "brew"}
+	idx := index.Index{brew: {
+		"jq": {Provider: brew, Name: "jq", Version: "1.7.1"},
+		"fd": {Provider: brew, Name: "fd", Version: "9.0.0"},
+	}}
+	cases := []struct {
+		result   Result
+		expected Outcome
+		err      error
+		version  string
+		output   string
+	}{
+		{Result{Package: compulsive.Package{Provider: brew, Name: "jq", Version: "1.7"}, Output: "done"}, OutcomeUpgraded, nil, "1.7.1", ""},
+		{Result{Package: compulsive.Package{Provider: brew, Name: "fd", Version: "9.0.0"}, Output: "no bottle"}, OutcomeUnchanged, nil, "9.0.0", "no bottle"},
+		{Result{Package: compulsive.Package{Provider: brew, Name: "rg", Version: "14.0.0"}}, OutcomeFailed, compulsive.ErrPackageNotFound, "", ""},
+		{Result{Package: compulsive.Package{Provider: fakeProvider{name: "pip"}, Name: "black"}}, OutcomeFailed, compulsive.ErrProviderUnavailable, "", ""},
+		{Result{Package: compulsive.Package{Provider: brew, Name: "jq", Version: "1.7"}, Err: errors.New("exit status 1")}, OutcomeFailed, nil, "", ""},
+	}
+	for i, it := range cases {
+		res := it.result
+		got := checkOutcome(idx, &res)
+		if got != it.expected || res.NewVersion != it.version || res.Output != it.output {
+			t.Errorf("case %d: got %s %+v", i, got, res)
+		}
+		if it.err != nil && res.Err != it.err {
+			t.Errorf("case %d: got error %v", i, res.Err)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package compulsive
+
+import (
+	"strconv"
+	"strings"
+)
+
+func splitVersion(version string) ([]string, string) {
+	version = strings.TrimPrefix(version, "v")
+	if i := strings.IndexByte(version, '+'); i >= 0 {
+		version = version[:i]
+	}
+	pre := ""
+	if i := strings.IndexByte(version, '-'); i >= 0 {
+		version, pre = version[:i], version[i+1:]
+	}
+	return strings.Split(version, "."), pre
+}
+
+// CompareVersions compares two dotted version strings, returning -1, 0 or 1.
+// Numeric fields are compared numerically and a prerelease sorts before the
+// release it belongs to.
+func CompareVersions(a, b string) int {
+	fieldsA, preA := splitVersion(a)
+	fieldsB, preB := splitVersion(b)
+	for i := 0; i < len(fieldsA) || i < len(fieldsB); i++ {
+		var fa, fb string
+		if i < len(fieldsA) {
+			fa = fieldsA[i]
+		}
+		if i < len(fieldsB) {
+			fb = fieldsB[i]
+		}
+		if c := compareField(fa, fb); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case preA == preB:
+		return 0
+	case preA == "":
+		return 1
+	case preB == "":
+		return -1
+	case preA < preB:
+		return -1
+	}
+	return 1
+}
+
+// splitField separates the leading number of a version field from its
+// suffix, e.g. "21rc2" gives 21 and "rc2".
+func splitField(field string) (int, string, bool) {
+	i := 0
+	for i < len(field) && field[i] >= '0' && field[i] <= '9' {
+		i++
+	}
+	if i == 0 {
+		return 0, field, field == ""
+	}
+	n, _ := strconv.Atoi(field[:i])
+	return n, field[i:], true
+}
+
+func compareField(a, b string) int {
+	na, suffixA, okA := splitField(a)
+	nb, suffixB, okB := splitField(b)
+	if !okA || !okB {
+		return strings.Compare(a, b)
+	}
+	switch {
+	case na < nb:
+		return -1
+	case na > nb:
+		return 1
+	case suffixA == suffixB:
+		return 0
+	case suffixA == "":
+		return -compareSuffix(suffixB)
+	case suffixB == "":
+		return compareSuffix(suffixA)
+	}
+	return strings.Compare(suffixA, suffixB)
+}
+
+var prereleaseMarkers = []string{"alpha", "beta", "pre", "rc"}
+
+// compareSuffix compares a version field having a suffix with the same field
+// without it. Prerelease markers sort before the release, e.g. 1.21rc2 <
+// 1.21, other suffixes after, e.g. a revision as in 1.2.3_1 > 1.2.3.
+func compareSuffix(suffix string) int {
+	marker := strings.ToLower(strings.TrimLeft(suffix, "-_."))
+	for _, it := range prereleaseMarkers {
+		if rest := strings.TrimPrefix(marker, it); rest != marker && (rest == "" || rest[0] >= '0' && rest[0] <= '9' || rest[0] == '.') {
+			return -1
+		}
+	}
+	return 1
+}
+
+type VersionChange string
+
+const (
+	ChangeMajor   VersionChange = "major"
+	ChangeMinor   VersionChange = "minor"
+	ChangePatch   VersionChange = "patch"
+	ChangeUnknown VersionChange = "unknown"
+)
+
+// ClassifyChange tells which version field an upgrade from one version to
+// another bumps. Leading zero fields do not count, as with semver, bumping
+// 0.x to 0.y being a breaking change. The change is unknown when versions
+// are the same, e.g. for a rebuild.
+func ClassifyChange(from, to string) VersionChange {
+	fieldsFrom, preFrom := splitVersion(from)
+	fieldsTo, preTo := splitVersion(to)
+	if from == "" || to == "" {
+		return ChangeUnknown
+	}
+	for i := 0; i < len(fieldsFrom) || i < len(fieldsTo); i++ {
+		var a, b string
+		if i < len(fieldsFrom) {
+			a = fieldsFrom[i]
+		}
+		if i < len(fieldsTo) {
+			b = fieldsTo[i]
+		}
+		if compareField(a, b) == 0 {
+			continue
+		}
+		level := i
+		for j := 0; j < i && (j >= len(fieldsFrom) || fieldsFrom[j] == "0"); j++ {
+			level--
+		}
+		switch level {
+		case 0:
+			return ChangeMajor
+		case 1:
+			return ChangeMinor
+		}
+		return ChangePatch
+	}
+	if preFrom != preTo {
+		return ChangePatch
+	}
+	return ChangeUnknown
+}
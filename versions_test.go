@@ -0,0 +1,49 @@
+package compulsive
+
+import "testing"
+
+func TestCompareVersions(t *testing.T) {
+	cases := []struct {
+		a, b     string
+		expected int
+	}{
+		{"1.2.3", "1.2.3", 0},
+		{"1.2.3", "1.10.0", -1},
+		{"v2.0.0", "1.9.9", 1},
+		{"1.0.0-rc.1", "1.0.0", -1},
+		{"1.0", "1.0.0", 0},
+		{"1.21rc2", "1.21", -1},
+		{"1.21beta1", "1.21.0", -1},
+		{"1.2.3_1", "1.2.3", 1},
+		{"1.2.3", "1.2.3_1", -1},
+		{"1.1.1w", "1.1.1", 1},
+	}
+	for _, it := range cases {
+		if got := CompareVersions(it.a, it.b); got != it.expected {
+			t.Errorf("CompareVersions(%q, %q) = %d, expected %d", it.a, it.b, got, it.expected)
+		}
+	}
+}
+
+func TestClassifyChange(t *testing.T) {
+	cases := []struct {
+		from, to string
+		expected VersionChange
+	}{
+		{"1.2.3", "2.0.0", ChangeMajor},
+		{"1.2.3", "1.3.0", ChangeMinor},
+		{"1.2.3", "1.2.4", ChangePatch},
+		{"0.2.3", "0.3.0", ChangeMajor},
+		{"0.2.3", "0.2.4", ChangeMinor},
+		{"0.0.3", "0.0.4", ChangeMajor},
+		{"0", "0.0.1", ChangeMajor},
+		{"1.2.3", "", ChangeUnknown},
+		{"1.2.3", "1.2.3", ChangeUnknown},
+		{"1.2.3-rc1", "1.2.3", ChangePatch},
+	}
+	for _, it := range cases {
+		if got := ClassifyChange(it.from, it.to); got != it.expected {
+			t.Errorf("ClassifyChange(%q, %q) = %s, expected %s", it.from, it.to, got, it.expected)
+		}
+	}
+}